@@ -1,7 +1,8 @@
 package config
 
 import (
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"path"
 	"strings"
@@ -9,7 +10,7 @@ import (
 	"github.com/altid/libs/config/internal/conf"
 	"github.com/altid/libs/config/internal/entry"
 	"github.com/altid/libs/config/internal/util"
-	"github.com/altid/libs/service"
+	"github.com/altid/libs/internal/dirs"
 	"github.com/mischief/ndb"
 )
 
@@ -26,24 +27,21 @@ import (
 //			Foo     string     // Will use default
 //		}{"127.0.0.1", "password", false, "bar"}
 //
-//		if e := config.Marshal(&conf, "zzyzx", "", false); e != nil {
+//		if e := config.Marshal(&conf, "zzyzx", "", nil); e != nil {
 //			log.Fatal(e)
 //		}
 //	 [...]
-func Marshal(requested any, service string, configFile string, debug bool) error {
-	debugLog := func(string, ...any) {}
-	if debug {
-		debugLog = func(format string, v ...any) {
-			l := log.New(os.Stdout, "config: ", 0)
-			l.Printf(format+"\n", v...)
-		}
-	}
+//
+// Entries are logged to logger at the debug level, with secrets such as passwords redacted
+// A nil logger discards all output
+func Marshal(requested any, service string, configFile string, logger *slog.Logger) error {
+	logger = withService(logger, service)
 	// list all existing config entries
-	have, err := entry.FromConfig(debugLog, service, configFile)
+	have, err := entry.FromConfig(logger, service, configFile)
 	if err != nil {
 		return err
 	}
-	if e := conf.Marshal(debugLog, requested, have, nil); e != nil {
+	if e := conf.Marshal(logger, requested, have, nil); e != nil {
 		return e
 	}
 	return nil
@@ -60,7 +58,7 @@ func Marshal(requested any, service string, configFile string, debug bool) error
 //			Foo     string     // Will use default
 //		}{"127.0.0.1", "password", false, "bar"}
 //
-//		if e := config.Create(&conf, "zzyzx", "", false); e != nil {
+//		if e := config.Create(&conf, "zzyzx", "", nil); e != nil {
 //			log.Fatal(e)
 //		}
 //
@@ -70,40 +68,41 @@ func Marshal(requested any, service string, configFile string, debug bool) error
 // for the value on the command line, optionally with a whitelisted array of selections to pick from
 // Selection of an item not on a whitelist will return an error after 3 attempts
 // The `pick` option to a types.Auth will be ignored, and will always be one of `password|factotum|none`
-func Create(requests any, svc, configFile string, debug bool) error {
-	debugLog := func(string, ...any) {}
-	if debug {
-		debugLog = func(format string, v ...any) {
-			l := log.New(os.Stdout, "config: ", 0)
-			l.Printf(format+"\n", v...)
-		}
-	}
-	have, err := entry.FromConfig(debugLog, svc, configFile)
+func Create(requests any, svc, configFile string, logger *slog.Logger) error {
+	logger = withService(logger, svc)
+	have, err := entry.FromConfig(logger, svc, configFile)
 	// Make sure we correct any errors we encounter
 	switch {
 	case err == nil:
-		debugLog("no errors in config")
+		logger.Debug("no errors in config")
 	case os.IsNotExist(err):
-		dir, _ := service.UserConfDir()
+		dir, _ := dirs.UserConfDir()
 		os.MkdirAll(path.Join(dir, "altid"), 0755)
 		os.Create(util.GetConf())
-		debugLog("creating config file")
+		logger.Info("creating config file", "file", util.GetConf())
 	// If we have multiple entries, something has indeed gone wrong
 	// The user needs to manually clean this up
 	case err.Error() == entry.ErrMultiEntries:
 		return err
 	// This is the expected case in this situation
 	case err.Error() == entry.ErrNoEntries:
-		debugLog("creating entry")
+		logger.Info("creating entry")
 	default:
-		debugLog("error: %v\n", err)
+		logger.Warn("unable to read config", "err", err)
 	}
-	if e := conf.Marshal(debugLog, requests, have, conf.NewPrompt(debugLog)); e != nil {
+	if e := conf.Marshal(logger, requests, have, conf.NewPrompt(logger)); e != nil {
 		return e
 	}
 	return conf.WriteOut(svc, requests)
 }
 
+func withService(logger *slog.Logger, service string) *slog.Logger {
+	if logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return logger.With("service", service)
+}
+
 // GetListenAddress returns the listen_address of a server, or "" if none is found
 // If a port is set, e.g. listen_address = 192.168.0.4:8080 it will return 8080
 func GetListenAddress(service string) (string, string) {
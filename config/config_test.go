@@ -11,7 +11,7 @@ func TestMarshal(t *testing.T) {
 		Foo     string     // Will use default
 	}{"127.0.0.1", false, "bar"}
 
-	if e := Marshal(&conf, "zzyzx", "resources/marshal_config", true); e != nil {
+	if e := Marshal(&conf, "zzyzx", "resources/marshal_config", nil); e != nil {
 		t.Error(e)
 	}
 
@@ -33,7 +33,7 @@ func TestCreate(t *testing.T) {
 		Listen  ListenAddress
 	}{"irc.freenode.net", 1234, "", ""}
 
-	if e := Create(&conf, "zzyzx", "resources/create_config", true); e != nil {
+	if e := Create(&conf, "zzyzx", "resources/create_config", nil); e != nil {
 		t.Error(e)
 	}
 }
@@ -44,7 +44,7 @@ Example:
 		}{u.Name, false, 564, "none", "", ""}
 
 		if flag.Lookup("conf") != nil {
-			if e := config.Marshall(&mytype, "myservice", false); e != nil {
+			if e := config.Create(&mytype, "myservice", "", nil); e != nil {
 				log.Fatal(e)
 			}
 
@@ -52,7 +52,7 @@ Example:
 		}
 
 		// Your error message should indicate that the user re-runs with -conf to create any missing entries
-		if e := config.Marshall(&mytype, "myservice", false); e != nil {
+		if e := config.Marshal(&mytype, "myservice", "", nil); e != nil {
 			log.Fatal("unable to create config: %v\nRun program with -conf to create missing entries")
 		}
 
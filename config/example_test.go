@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"log"
+	"log/slog"
 
 	"github.com/altid/libs/config"
 )
@@ -13,7 +14,7 @@ func ExampleMarshal() {
 		Foo     string     // Will use default
 	}{"127.0.0.1", false, "bar"}
 
-	if e := config.Marshal(&conf, "zzyzx", "resources/marshal_config", false); e != nil {
+	if e := config.Marshal(&conf, "zzyzx", "resources/marshal_config", nil); e != nil {
 		log.Fatal(e)
 	}
 }
@@ -24,7 +25,7 @@ func ExampleCreate() {
 		Port    int                 `altid:"port,no_prompt"`
 	}{"irc.freenode.net", 1234}
 
-	if e := config.Create(&conf, "zzyzx", "resources/create_config", true); e != nil {
+	if e := config.Create(&conf, "zzyzx", "resources/create_config", slog.Default()); e != nil {
 		log.Fatal(e)
 	}
 }
@@ -2,13 +2,14 @@ package conf
 
 import (
 	"fmt"
+	"log/slog"
 	"reflect"
 
 	"github.com/altid/libs/config/internal/entry"
 	"github.com/altid/libs/config/internal/request"
 )
 
-func Marshal(debug func(string, ...any), requests any, have []*entry.Entry, p Prompter) error {
+func Marshal(log *slog.Logger, requests any, have []*entry.Entry, p Prompter) error {
 	// Loop through entries and attempt to fill the struct
 	// Make sure we turn any ints that are supposed to be strings,
 	// back into strings!
@@ -25,6 +26,7 @@ func Marshal(debug func(string, ...any), requests any, have []*entry.Entry, p Pr
 		if e := push(requests, en); e != nil {
 			return e
 		}
+		log.Debug("set", "entry", en)
 	}
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
@@ -18,21 +19,21 @@ type Prompter interface {
 
 // Prompt just queries for things
 type Prompt struct {
-	debug func(string, ...any)
+	log *slog.Logger
 }
 
-func NewPrompt(debug func(string, ...any)) *Prompt {
+func NewPrompt(log *slog.Logger) *Prompt {
 	return &Prompt{
-		debug: debug,
+		log: log,
 	}
 }
 
 func (p *Prompt) Query(req *request.Request) (*entry.Entry, error) {
 	key := strings.ToLower(req.Key)
+	p.log.Debug("request", "key", key, "default", entry.Redact(key, req.Defaults))
 	entry := &entry.Entry{
 		Key: key,
 	}
-	p.debug("request key=\"%s\" default=\"%v\"", key, req.Defaults)
 	switch {
 	case req.Defaults == nil:
 		return nil, errors.New("request defaults cannot be nil")
@@ -55,7 +56,7 @@ func (p *Prompt) Query(req *request.Request) (*entry.Entry, error) {
 		// User pressed enter for default
 		if value == "" || value == "\n" {
 			entry.Value = req.Defaults
-			p.debug("response key=\"%s\" value=\"%v\"", entry.Key, entry.Value)
+			p.log.Debug("response", "entry", entry)
 			return entry, nil
 		}
 		if checkPicks(value, req.Pick) {
@@ -71,34 +72,30 @@ func (p *Prompt) Query(req *request.Request) (*entry.Entry, error) {
 	case bool:
 		entry.Value, err = strconv.ParseBool(value)
 		if err != nil {
-			p.debug("error: %v\n", err)
+			p.log.Warn("invalid bool", "key", entry.Key, "err", err)
 		}
-		p.debug("response key=\"%s\" value=\"%t\"", entry.Key, entry.Value)
 	case string:
 		entry.Value = value
-		p.debug("response key=\"%s\" value=\"%s\"", entry.Key, entry.Value)
 	case float32:
 		v, err := strconv.ParseFloat(value, 32)
 		if err != nil {
 			return nil, err
 		}
 		entry.Value = v
-		p.debug("response key=\"%s\" value=\"%f\"", v)
 	case float64:
 		v, err := strconv.ParseFloat(value, 64)
 		if err != nil {
 			return nil, err
 		}
 		entry.Value = v
-		p.debug("response key=\"%s\" value=\"%f\"", v)
 	default:
 		v, e := tryInt(req.Defaults, value)
 		if e != nil {
 			return nil, e
 		}
 		entry.Value = v
-		p.debug("response key=\"%s\" value=\"%d\"", entry.Key, entry.Value)
 	}
+	p.log.Debug("response", "entry", entry)
 	return entry, nil
 }
 
@@ -3,6 +3,7 @@ package entry
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
 
 	"github.com/altid/libs/config/internal/util"
@@ -23,7 +24,31 @@ type Entry struct {
 	Value any
 }
 
-func FromConfig(debug func(string, ...any), service string, cf string) ([]*Entry, error) {
+// Redact returns value, or a placeholder if key holds a secret
+// An auth value other than one of the known mechanisms is a password entered at the prompt
+func Redact(key string, value any) any {
+	switch key {
+	case "password":
+		return "[redacted]"
+	case "auth":
+		switch fmt.Sprint(value) {
+		case "password", "factotum", "none":
+			return value
+		}
+		return "[redacted]"
+	}
+	return value
+}
+
+// LogValue allows entries to be logged safely, redacting secrets
+func (item *Entry) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("key", item.Key),
+		slog.Any("value", Redact(item.Key, item.Value)),
+	)
+}
+
+func FromConfig(log *slog.Logger, service string, cf string) ([]*Entry, error) {
 	dir := util.GetConf()
 	if cf != "" {
 		dir = cf
@@ -37,7 +62,8 @@ func FromConfig(debug func(string, ...any), service string, cf string) ([]*Entry
 	case 0:
 		return nil, errors.New(ErrNoEntries)
 	case 1:
-		return fromNdb(debug, recs, service)
+		log.Debug("found config entry", "service", service, "file", dir)
+		return fromNdb(log, recs, service)
 	default:
 		return nil, errors.New(ErrMultiEntries)
 	}
@@ -59,7 +85,7 @@ func (item *Entry) String() string {
 }
 
 // This will error if auth=password has no complementary password=field
-func fromNdb(debug func(string, ...any), recs ndb.RecordSet, service string) ([]*Entry, error) {
+func fromNdb(log *slog.Logger, recs ndb.RecordSet, service string) ([]*Entry, error) {
 	var values []*Entry
 	for _, tup := range recs[0] {
 		v := &Entry{
@@ -77,6 +103,7 @@ func fromNdb(debug func(string, ...any), recs ndb.RecordSet, service string) ([]
 		if num, err := strconv.ParseInt(tup.Val, 10, 0); err == nil {
 			v.Value = int(num)
 		}
+		log.Debug("read", "entry", v)
 		values = append(values, v)
 	}
 	return values, nil
@@ -1,13 +1,15 @@
 package entry
 
 import (
-	"log"
+	"bytes"
+	"log/slog"
+	"strings"
 	"testing"
 )
 
 func TestFromConfig(t *testing.T) {
 	// Make up a tmp file for testing
-	db, err := FromConfig(log.Printf, "banana", "resources/config")
+	db, err := FromConfig(slog.Default(), "banana", "resources/config")
 	if err != nil {
 		t.Error(err)
 	}
@@ -16,3 +18,32 @@ func TestFromConfig(t *testing.T) {
 		t.Error("unable to find all entries")
 	}
 }
+
+func TestLogValue(t *testing.T) {
+	var b bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if _, err := FromConfig(log, "banana", "resources/config"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "banana2") {
+		t.Errorf("password logged: %s", b.String())
+	}
+	if !strings.Contains(b.String(), "entry.key=port entry.value=1234") {
+		t.Errorf("entries not logged: %s", b.String())
+	}
+
+	for _, tc := range []struct {
+		key   string
+		value any
+		want  any
+	}{
+		{"password", "hunter2", "[redacted]"},
+		{"auth", "factotum", "factotum"},
+		{"auth", "hunter2", "[redacted]"},
+		{"port", 1234, 1234},
+	} {
+		if have := Redact(tc.key, tc.value); have != tc.want {
+			t.Errorf("%s: have %v, want %v", tc.key, have, tc.want)
+		}
+	}
+}
@@ -4,11 +4,11 @@ import (
 	"log"
 	"path"
 
-	"github.com/altid/libs/service"
+	"github.com/altid/libs/internal/dirs"
 )
 
 func GetConf() string {
-	confdir, err := service.UserConfDir()
+	confdir, err := dirs.UserConfDir()
 	if err != nil {
 		log.Fatal(err)
 	}
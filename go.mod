@@ -1,6 +1,6 @@
 module github.com/altid/libs
 
-go 1.18
+go 1.21
 
 require (
 	github.com/google/gofuzz v1.2.0
@@ -2,6 +2,7 @@ package callback
 
 import (
 	"github.com/altid/libs/markup"
+	"github.com/altid/libs/service/commander"
 	"github.com/altid/libs/service/controller"
 )
 
@@ -30,4 +31,57 @@ type Sender interface {
 // Starter is called to start the main loop of the client
 type Starter interface {
 	Start(controller.Controller) error
-}
\ No newline at end of file
+}
+
+// Opener is an optional interface, called when a client runs `open <buffer>`
+// A chat service would typically join the channel and create the buffer here
+type Opener interface {
+	Open(buffer string) error
+}
+
+// Closer is an optional interface, called when a client runs `close <buffer>`
+type Closer interface {
+	Close(buffer string) error
+}
+
+// Switcher is an optional interface, called when a client runs `buffer <buffer>` to change to an open buffer
+type Switcher interface {
+	Switch(buffer string) error
+}
+
+// Linker is an optional interface, called when a client runs `link <current> <buffer>` to replace the current buffer with another
+type Linker interface {
+	Link(current, buffer string) error
+}
+
+// Disconnecter is an optional interface, called when a client disconnects from the service
+type Disconnecter interface {
+	Disconnect(username string) error
+}
+
+// Runner is an optional interface, called when a client runs one of the service's own commands, such as those set with service.WithCommands
+// cmd.Name is always the command's name, even if it was run by an alias
+// A non-nil Result is sent back to the buffer the command was run from, so the client can show it inline
+type Runner interface {
+	Run(cmd *commander.Command) (*commander.Result, error)
+}
+
+// Completer is an optional interface, called when a client asks for completions of a partial line
+// It returns the values param of cmd may take in buffer which start with prefix, such as nicknames
+// Buffer names and enum values are completed without it
+type Completer interface {
+	Complete(buffer string, cmd *commander.Command, param commander.Param, prefix string) []string
+}
+
+// Reloader is an optional interface, called when the service is asked to reload
+// Any config set with service.WithConfig has already been marshalled again when Reload is called
+type Reloader interface {
+	Reload() error
+}
+
+// ErrorHandler is an optional interface, called when running a command or handling input fails
+// buffer is the buffer the command or input came from, and cmd is the failed command; input is a command named "input"
+// The returned error is written to the client's error file in place of err; returning nil suppresses it
+type ErrorHandler interface {
+	HandleError(buffer string, cmd *commander.Command, err error) error
+}
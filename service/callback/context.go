@@ -0,0 +1,77 @@
+package callback
+
+import (
+	"context"
+
+	"github.com/altid/libs/markup"
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/controller"
+)
+
+// ContextConnecter is preferred over Connecter when a service implements it
+// ctx carries the username, see Username
+type ContextConnecter interface {
+	ConnectContext(ctx context.Context, username string) error
+}
+
+// ContextHandler is preferred over Handler when a service implements it
+// ctx is cancelled when the service shuts down, may carry a deadline, and holds the buffer and username for the input
+type ContextHandler interface {
+	HandleContext(ctx context.Context, path string, c *markup.Lexer) error
+}
+
+// ContextRunner is preferred over Runner when a service implements it
+// ctx is cancelled when the service shuts down, may carry a deadline, and holds the buffer and username for the command
+type ContextRunner interface {
+	RunContext(ctx context.Context, cmd *commander.Command) (*commander.Result, error)
+}
+
+// AsyncRunner is called instead of Runner for commands marked Async, each on its own goroutine
+// ctx is cancelled when the user runs `cancel <id>` or the service shuts down, and holds the buffer and username for the command
+// Progress written to p is shown in the status file of the buffer the command came from
+// A non-nil Result is sent back to that buffer once the command finishes
+type AsyncRunner interface {
+	RunAsync(ctx context.Context, cmd *commander.Command, p Progress) (*commander.Result, error)
+}
+
+// Progress reports how far along an async command is
+type Progress interface {
+	// Update sets the status of the command, such as "fetched 200 messages"
+	// done and total give how much is complete; a total of 0 means the total is unknown
+	Update(msg string, done, total int) error
+}
+
+// ContextStarter is preferred over Starter when a service implements it
+// ctx is cancelled when the service shuts down
+type ContextStarter interface {
+	StartContext(ctx context.Context, c controller.Controller) error
+}
+
+type ctxKey int
+
+const (
+	usernameKey ctxKey = iota
+	bufferKey
+)
+
+// WithUsername returns a copy of ctx holding the username of the client a request came from
+func WithUsername(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, usernameKey, username)
+}
+
+// Username returns the username of the client a request came from, if known
+func Username(ctx context.Context) (string, bool) {
+	u, ok := ctx.Value(usernameKey).(string)
+	return u, ok
+}
+
+// WithBuffer returns a copy of ctx holding the buffer a request came from
+func WithBuffer(ctx context.Context, buffer string) context.Context {
+	return context.WithValue(ctx, bufferKey, buffer)
+}
+
+// Buffer returns the buffer a request came from, if known
+func Buffer(ctx context.Context) (string, bool) {
+	b, ok := ctx.Value(bufferKey).(string)
+	return b, ok
+}
@@ -6,11 +6,11 @@ import (
 	"strings"
 )
 
-// ComGroup is a logical grouping of commands
-// To add a ComGroup, please do so in a PR
+// ComGroup is a logical grouping of commands, shown under its own heading in the ctl file
+// Services may add their own with RegisterGroup
 type ComGroup int
 
-// Currently supported ComGroups
+// Built in ComGroups
 const (
 	DefaultGroup ComGroup = iota
 	ActionGroup
@@ -27,34 +27,74 @@ type Commander interface {
 	FromBytes([]byte) (*Command, error)
 	FindCommand(string, []*Command) (*Command, error)
 	WriteCommands([]*Command, io.Writer) error
+	// Complete returns ranked candidates for the last word of line, typed in buffer
+	// The first word completes to the names and aliases in the list, and later words to the Params of the named command, using src where given
+	Complete(line, buffer string, cmdlist []*Command, src Source) []Candidate
 }
 
 // Allow sorting of our lists
+// Commands sort by the Order of their group, with unregistered groups last
 type CmdList []*Command
 
-func (a CmdList) Len() int           { return len(a) }
-func (a CmdList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a CmdList) Less(i, j int) bool { return a[i].Heading < a[j].Heading }
+func (a CmdList) Len() int      { return len(a) }
+func (a CmdList) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a CmdList) Less(i, j int) bool {
+	gi, iok := a[i].Heading.Group()
+	gj, jok := a[j].Heading.Group()
+	switch {
+	case iok != jok:
+		return iok
+	case gi.Order != gj.Order:
+		return gi.Order < gj.Order
+	}
+	if a[i].Heading != a[j].Heading {
+		return a[i].Heading < a[j].Heading
+	}
+	return a[i].HeadingName < a[j].HeadingName
+}
 
 // Command represents an available command to a service
 // The From field should generally be populated, except in the case of a ServiceGroup command
+// Async commands run in the background as a job, which the user can list and cancel with JobCommands
+// A command with Params has its arguments checked against them before it is run, and the Params are shown in place of Args
+// A command read under a heading which isn't registered has a Heading of UnknownGroup, with the heading in HeadingName
 type Command struct {
 	Name        string
 	Description string
 	Heading     ComGroup
+	HeadingName string
 	Args        []string
 	Alias       []string
 	From        string
+	Async       bool
+	Params      []Param
 }
 
+// String returns the command as written to the ctl file
+// Args are quoted where needed, so the command reads back the same
 func (c *Command) String() string {
-	args := strings.Join(c.Args, " ")
+	quoted := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		quoted[i] = Quote(arg)
+	}
+	args := strings.Join(quoted, " ")
 	if c.From != "" {
 		return fmt.Sprintf("%s %s\n\t%s\n", c.Name, c.From, args)
 	}
 	return fmt.Sprintf("%s %s\n", c.Name, args)
 }
 
+// Quote returns arg quoted so that it reads back as a single argument, or arg itself if it needs no quotes
+// Newlines, carriage returns and tabs are escaped as \n, \r and \t, so a quoted arg stays on one line
+func Quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\r\n\"'\\") {
+		return arg
+	}
+	return `"` + quoter.Replace(arg) + `"`
+}
+
+var quoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
 func (c *Command) ArgBytes() []byte {
 	args := strings.Join(c.Args, " ")
 	return []byte(args)
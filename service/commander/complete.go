@@ -0,0 +1,14 @@
+package commander
+
+// Candidate is a possible completion of the last word of a partial line
+type Candidate struct {
+	// Text replaces the last word of the line
+	Text string
+	// Description says what Text is, such as a command's Description or the Param it fills
+	Description string
+}
+
+// Source returns the values a Param of cmd may take which start with prefix, such as nicknames or buffer names
+// buffer is the buffer the line is being typed in
+// EnumParam values are completed without calling Source
+type Source func(buffer string, cmd *Command, param Param, prefix string) []string
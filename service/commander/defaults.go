@@ -33,3 +33,28 @@ var DefaultCommands = []*Command{
 		Description: "Exits the client",
 	},
 }
+
+// Reserved reports whether name is a command handled by the service library itself, which a service can't offer as its own
+func Reserved(name string) bool {
+	switch name {
+	case "complete", "jobs", "cancel":
+		return true
+	}
+	return false
+}
+
+// JobCommands are added to the commands of a service with any Async commands
+var JobCommands = []*Command{
+	{
+		Name:        "jobs",
+		Args:        []string{},
+		Heading:     ServiceGroup,
+		Description: "List running and recently finished commands",
+	},
+	{
+		Name:        "cancel",
+		Args:        []string{"<id>"},
+		Heading:     ServiceGroup,
+		Description: "Cancel the running command with the given id",
+	},
+}
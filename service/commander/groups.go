@@ -0,0 +1,122 @@
+package commander
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Group describes a ComGroup
+type Group struct {
+	// Name is the heading written to the ctl file, such as "moderation"
+	Name string
+	// Title is shown to the user, such as "Moderation"
+	Title string
+	// Order sorts the group among the others, lowest first
+	Order int
+}
+
+// UnknownGroup is the Heading of a command read from a ctl file under a heading which was never registered
+// The heading itself is kept in the command's HeadingName, so reading a ctl file never adds to the registry
+const UnknownGroup ComGroup = -1
+
+// Groups are kept in the order they were registered, so a ComGroup indexes the list
+var groups = struct {
+	sync.RWMutex
+	list []Group
+}{
+	list: []Group{
+		DefaultGroup: {Name: "general", Title: "General", Order: 0},
+		ActionGroup:  {Name: "emotes", Title: "Emotes", Order: 100},
+		MediaGroup:   {Name: "media", Title: "Media", Order: 200},
+		ServiceGroup: {Name: "service", Title: "Service", Order: 300},
+	},
+}
+
+// RegisterGroup adds a group of commands with the given heading name, title and sort order, returning its ComGroup
+// Registering a name again returns the existing ComGroup, with its title and order updated
+// The name becomes a heading in the ctl file, so it can't contain whitespace, ':', '#', '<', '>' or '|'
+func RegisterGroup(name, title string, order int) (ComGroup, error) {
+	if !ValidGroupName(name) {
+		return 0, fmt.Errorf("invalid group name %q", name)
+	}
+	if title == "" {
+		title = name
+	}
+	groups.Lock()
+	defer groups.Unlock()
+	g := Group{Name: name, Title: title, Order: order}
+	for i, have := range groups.list {
+		if have.Name == name {
+			groups.list[i] = g
+			return ComGroup(i), nil
+		}
+	}
+	groups.list = append(groups.list, g)
+	return ComGroup(len(groups.list) - 1), nil
+}
+
+// ValidGroupName reports whether name can be used as a heading in the ctl file
+func ValidGroupName(name string) bool {
+	return name != "" && strings.IndexFunc(name, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(":#<>|", r)
+	}) < 0
+}
+
+// MustRegisterGroup is RegisterGroup, but panics on an invalid name
+// It's intended for package level variables, such as
+//
+//	var ModGroup = commander.MustRegisterGroup("moderation", "Moderation", 150)
+func MustRegisterGroup(name, title string, order int) ComGroup {
+	g, err := RegisterGroup(name, title, order)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// GroupByName returns the ComGroup registered with the given heading name
+func GroupByName(name string) (ComGroup, bool) {
+	groups.RLock()
+	defer groups.RUnlock()
+	for i, g := range groups.list {
+		if g.Name == name {
+			return ComGroup(i), true
+		}
+	}
+	return 0, false
+}
+
+// Groups returns every registered ComGroup, sorted by Order
+func Groups() []ComGroup {
+	groups.RLock()
+	defer groups.RUnlock()
+	all := make([]ComGroup, len(groups.list))
+	for i := range all {
+		all[i] = ComGroup(i)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return groups.list[all[i]].Order < groups.list[all[j]].Order
+	})
+	return all
+}
+
+// Group returns the description of g, and false if g was never registered
+func (g ComGroup) Group() (Group, bool) {
+	groups.RLock()
+	defer groups.RUnlock()
+	if g < 0 || int(g) >= len(groups.list) {
+		return Group{}, false
+	}
+	return groups.list[g], true
+}
+
+// String returns the heading name of g
+func (g ComGroup) String() string {
+	if desc, ok := g.Group(); ok {
+		return desc.Name
+	}
+	return fmt.Sprintf("ComGroup(%d)", int(g))
+}
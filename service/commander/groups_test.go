@@ -0,0 +1,64 @@
+package commander
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestRegisterGroup(t *testing.T) {
+	mod, err := RegisterGroup("moderation", "Moderation", 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, ok := GroupByName("moderation"); !ok || g != mod {
+		t.Errorf("moderation not found by name, have %v", g)
+	}
+	if desc, _ := mod.Group(); desc.Title != "Moderation" || mod.String() != "moderation" {
+		t.Errorf("unexpected group %+v", desc)
+	}
+
+	again, err := RegisterGroup("moderation", "Mods", 350)
+	if err != nil || again != mod {
+		t.Errorf("registering again should return the same group, have %v %v", again, err)
+	}
+	if desc, _ := mod.Group(); desc.Title != "Mods" || desc.Order != 350 {
+		t.Errorf("group not updated, have %+v", desc)
+	}
+
+	files, _ := RegisterGroup("files", "", 250)
+	if files.String() != "files" {
+		t.Errorf("unexpected name %q", files)
+	}
+	if desc, _ := MustRegisterGroup("files", "", 250).Group(); desc.Title != "files" {
+		t.Errorf("title should default to the name, have %q", desc.Title)
+	}
+
+	for _, bad := range []string{"", "two words", "admin:", "a|b", "#admin"} {
+		if _, err := RegisterGroup(bad, "", 0); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+	if _, ok := ComGroup(9001).Group(); ok {
+		t.Error("unregistered group reported as registered")
+	}
+
+	list := CmdList{
+		{Name: "unknown", Heading: 9001},
+		{Name: "ban", Heading: mod},
+		{Name: "kick", Heading: ActionGroup},
+		{Name: "upload", Heading: files},
+		{Name: "open", Heading: DefaultGroup},
+		{Name: "restart", Heading: ServiceGroup},
+	}
+	sort.Sort(list)
+	var have []string
+	for _, cmd := range list {
+		have = append(have, cmd.Name)
+	}
+	want := []string{"open", "kick", "upload", "restart", "ban", "unknown"}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Fatalf("have %q, want %q", have, want)
+		}
+	}
+}
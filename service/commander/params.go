@@ -0,0 +1,188 @@
+package commander
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParamType is the kind of value a Param accepts
+type ParamType int
+
+// Supported parameter types
+const (
+	// StringParam is a single word
+	StringParam ParamType = iota
+	// IntParam is a base 10 integer
+	IntParam
+	// BoolParam is anything strconv.ParseBool accepts
+	BoolParam
+	// EnumParam is one of the Param's Enum values
+	EnumParam
+	// BufferParam is the name of a buffer
+	BufferParam
+	// RestParam takes the rest of the line, spaces and all, and must be the last Param
+	RestParam
+)
+
+var paramTypes = [...]string{"", "int", "bool", "", "buffer", "line"}
+
+// Param describes a single typed argument of a Command
+// Params render to the ctl file as <name>, with the type after a colon, such as <count:int> or <mode:on|off>
+// Optional params are wrapped in brackets, <[count:int]>, and variadic params end in an ellipsis, <nicks...>
+type Param struct {
+	Name string
+	Type ParamType
+	// Enum lists the accepted values of an EnumParam
+	Enum []string
+	// Optional params may be left off the end of the line
+	Optional bool
+	// Variadic params take every remaining argument, at least one unless Optional, and must be the last Param
+	Variadic bool
+}
+
+func (p Param) String() string {
+	s := p.Name
+	switch p.Type {
+	case EnumParam:
+		s += ":" + strings.Join(p.Enum, "|")
+	case StringParam:
+	default:
+		s += ":" + paramTypes[p.Type]
+	}
+	if p.Variadic {
+		s += "..."
+	}
+	if p.Optional {
+		s = "[" + s + "]"
+	}
+	return "<" + s + ">"
+}
+
+// check returns an error if arg is not a valid value for p
+func (p Param) check(arg string) error {
+	switch p.Type {
+	case IntParam:
+		if _, err := strconv.Atoi(arg); err != nil {
+			return fmt.Errorf("%s: %q is not a number", p.Name, arg)
+		}
+	case BoolParam:
+		if _, err := strconv.ParseBool(arg); err != nil {
+			return fmt.Errorf("%s: %q is not true or false", p.Name, arg)
+		}
+	case EnumParam:
+		for _, e := range p.Enum {
+			if arg == e {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %q is not one of %s", p.Name, arg, strings.Join(p.Enum, ", "))
+	case BufferParam:
+		if arg == "" || strings.IndexFunc(arg, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+			return fmt.Errorf("%s: %q is not a buffer name", p.Name, arg)
+		}
+	}
+	return nil
+}
+
+// UsageError is returned when a command is run with arguments which don't match its Params
+type UsageError struct {
+	Usage string
+	Err   error
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%v; usage: %s", e.Err, e.Usage)
+}
+
+func (e *UsageError) Unwrap() error { return e.Err }
+
+// Usage returns how to run the command, such as "kick <nick> <[reason:line]>"
+func (c *Command) Usage() string {
+	usage := c.Name
+	for _, p := range c.Params {
+		usage += " " + p.String()
+	}
+	return usage
+}
+
+// Validate checks args against the command's Params, returning them ready for the service
+// Any RestParam is joined into a single argument; errors are a *UsageError
+// A command without Params accepts any arguments
+func (c *Command) Validate(args []string) ([]string, error) {
+	if len(c.Params) == 0 {
+		return args, nil
+	}
+	usage := func(format string, v ...any) error {
+		return &UsageError{Usage: c.Usage(), Err: fmt.Errorf(format, v...)}
+	}
+
+	var out []string
+	for i, p := range c.Params {
+		if i >= len(args) {
+			if p.Optional {
+				break
+			}
+			return nil, usage("missing %s", p.Name)
+		}
+		switch {
+		case p.Type == RestParam:
+			return append(out, strings.Join(args[i:], " ")), nil
+		case p.Variadic:
+			for _, arg := range args[i:] {
+				if e := p.check(arg); e != nil {
+					return nil, usage("%v", e)
+				}
+			}
+			return append(out, args[i:]...), nil
+		}
+		if e := p.check(args[i]); e != nil {
+			return nil, usage("%v", e)
+		}
+		out = append(out, args[i])
+	}
+	if len(args) > len(c.Params) {
+		return nil, usage("too many arguments")
+	}
+	return out, nil
+}
+
+// MaxArgs returns how many arguments a line should be split into for the command, or -1 for no limit
+// A RestParam is a single argument holding the rest of the line as written
+func (c *Command) MaxArgs() int {
+	n := len(c.Params)
+	if n == 0 || c.Params[n-1].Type != RestParam {
+		return -1
+	}
+	return n
+}
+
+// CheckParams returns an error if the Params of the command can't be satisfied, such as a required Param after an optional one
+func (c *Command) CheckParams() error {
+	optional := false
+	for i, p := range c.Params {
+		if p.Name == "" || strings.ContainsAny(p.Name, "<>[]|: \t\n") {
+			return fmt.Errorf("%s: invalid param name %q", c.Name, p.Name)
+		}
+		if (p.Variadic || p.Type == RestParam) && i != len(c.Params)-1 {
+			return fmt.Errorf("%s: %s must be the last param", c.Name, p.Name)
+		}
+		if p.Type == EnumParam && len(p.Enum) == 0 {
+			return fmt.Errorf("%s: %s has no enum values", c.Name, p.Name)
+		}
+		for _, e := range p.Enum {
+			if e == "" || strings.ContainsAny(e, "<>[]| \t\n") {
+				return fmt.Errorf("%s: %s has an invalid enum value %q", c.Name, p.Name, e)
+			}
+		}
+		if p.Type < StringParam || p.Type > RestParam {
+			return fmt.Errorf("%s: %s has an unknown type", c.Name, p.Name)
+		}
+		if optional && !p.Optional {
+			return fmt.Errorf("%s: required %s follows an optional param", c.Name, p.Name)
+		}
+		optional = optional || p.Optional
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package commander
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	kick := &Command{
+		Name: "kick",
+		Params: []Param{
+			{Name: "buffer", Type: BufferParam},
+			{Name: "nick"},
+			{Name: "reason", Type: RestParam, Optional: true},
+		},
+	}
+	mode := &Command{
+		Name: "mode",
+		Params: []Param{
+			{Name: "mode", Type: EnumParam, Enum: []string{"on", "off"}},
+			{Name: "limit", Type: IntParam, Optional: true},
+			{Name: "flags", Type: BoolParam, Optional: true, Variadic: true},
+		},
+	}
+
+	if u := kick.Usage(); u != "kick <buffer:buffer> <nick> <[reason:line]>" {
+		t.Errorf("unexpected usage %q", u)
+	}
+	if u := mode.Usage(); u != "mode <mode:on|off> <[limit:int]> <[flags:bool...]>" {
+		t.Errorf("unexpected usage %q", u)
+	}
+
+	for _, tc := range []struct {
+		cmd  *Command
+		args []string
+		want []string
+	}{
+		{kick, []string{"#altid", "halfwit"}, []string{"#altid", "halfwit"}},
+		{kick, []string{"#altid", "halfwit", "too", "much", "spam"}, []string{"#altid", "halfwit", "too much spam"}},
+		{mode, []string{"on"}, []string{"on"}},
+		{mode, []string{"off", "3", "true", "false"}, []string{"off", "3", "true", "false"}},
+		{&Command{Name: "any"}, []string{"a", "b"}, []string{"a", "b"}},
+	} {
+		have, err := tc.cmd.Validate(tc.args)
+		if err != nil {
+			t.Errorf("%s %q: %v", tc.cmd.Name, tc.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%s %q: have %q, want %q", tc.cmd.Name, tc.args, have, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		cmd  *Command
+		args []string
+	}{
+		{kick, []string{"#altid"}},
+		{mode, nil},
+		{mode, []string{"maybe"}},
+		{mode, []string{"on", "three"}},
+		{mode, []string{"on", "3", "yes please"}},
+		{&Command{Name: "one", Params: []Param{{Name: "a"}}}, []string{"a", "b"}},
+	} {
+		var ue *UsageError
+		if _, err := tc.cmd.Validate(tc.args); !errors.As(err, &ue) || ue.Usage != tc.cmd.Usage() {
+			t.Errorf("%s %q: expected a UsageError, have %v", tc.cmd.Name, tc.args, err)
+		}
+	}
+}
+
+func TestCheckParams(t *testing.T) {
+	for _, params := range [][]Param{
+		{{Name: ""}},
+		{{Name: "a b"}},
+		{{Name: "rest", Type: RestParam}, {Name: "after"}},
+		{{Name: "opt", Optional: true}, {Name: "required"}},
+		{{Name: "enum", Type: EnumParam}},
+		{{Name: "enum", Type: EnumParam, Enum: []string{"a|b"}}},
+		{{Name: "unknown", Type: 42}},
+	} {
+		if e := (&Command{Name: "bad", Params: params}).CheckParams(); e == nil {
+			t.Errorf("expected error for %+v", params)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package commander
+
+// Result is the reply to a command, sent back to the buffer or client which ran it
+// Text is markup, shown to the client as-is
+// Rows are structured output, such as a list of channels, with Columns optionally naming each field
+// Cells may not contain tabs or newlines
+type Result struct {
+	Text    string
+	Columns []string
+	Rows    [][]string
+}
+
+// AddRow appends a row of cells to the Result
+func (r *Result) AddRow(cells ...string) {
+	r.Rows = append(r.Rows, cells)
+}
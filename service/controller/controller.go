@@ -1,10 +1,18 @@
 package controller
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
 type Controller interface {
 	CreateBuffer(string) error
 	DeleteBuffer(string) error
 	Remove(string, string) error
+	// Notification sends a notification of normal urgency, with no timeout or actions
 	Notification(string, string, string) error
+	Notify(*Notification) error
 	ErrorWriter() (WriteCloser, error)
 	StatusWriter(string) (WriteCloser, error)
 	SideWriter(string) (WriteCloser, error)
@@ -14,9 +22,93 @@ type Controller interface {
 	MainWriter(string) (WriteCloser, error)
 	FeedWriter(string) (WriteCloser, error)
 	HasBuffer(string) bool
+	// Buffer returns the metadata of the named buffer, if it exists
+	Buffer(string) (Buffer, bool)
+	// Buffers returns the metadata of all buffers, sorted by name
+	Buffers() []Buffer
+	// RangeBuffers calls the function for each buffer in name order, until it returns false
+	RangeBuffers(func(Buffer) bool)
 }
 
 type WriteCloser interface {
 	Write(b []byte) (int, error)
 	Close() error
 }
+
+// Urgency is how strongly a client should draw the user's attention to a notification
+type Urgency int
+
+// Urgencies compare by severity, and the zero value is UrgencyNormal
+const (
+	UrgencyLow      Urgency = -1
+	UrgencyNormal   Urgency = 0
+	UrgencyCritical Urgency = 1
+)
+
+// Valid reports whether u is one of the defined urgencies
+func (u Urgency) Valid() bool {
+	switch u {
+	case UrgencyLow, UrgencyNormal, UrgencyCritical:
+		return true
+	}
+	return false
+}
+
+func (u Urgency) String() string {
+	switch u {
+	case UrgencyLow:
+		return "low"
+	case UrgencyCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// Action is offered to the user alongside a notification
+// When a client activates it, Command is sent back to the service as if the user had issued it
+type Action struct {
+	Label   string
+	Command string
+}
+
+// Notification is a message a client should alert the user to
+// The Buffer, From and Msg fields match those returned from markup.Notifier.Parse
+// A Timeout of zero leaves it up to the client how long the notification is shown
+type Notification struct {
+	Buffer  string
+	From    string
+	Msg     string
+	Urgency Urgency
+	Timeout time.Duration
+	Actions []Action
+}
+
+// Buffer is the metadata kept for each buffer a service creates
+// Unread counts writes to the main and feed files since a client last opened or switched to the buffer
+type Buffer struct {
+	Name     string
+	Title    string
+	Created  time.Time
+	Activity time.Time
+	Unread   int
+}
+
+var (
+	ErrBufferExists = errors.New("buffer already exists")
+	ErrNoBuffer     = errors.New("no such buffer")
+)
+
+// BufferError is returned when an operation conflicts with the buffers a service has
+// Err is one of ErrBufferExists or ErrNoBuffer
+type BufferError struct {
+	Op   string
+	Name string
+	Err  error
+}
+
+func (e *BufferError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Name, e.Err)
+}
+
+func (e *BufferError) Unwrap() error { return e.Err }
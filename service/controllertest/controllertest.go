@@ -0,0 +1,345 @@
+// Package controllertest provides a recording controller.Controller, for unit testing services without a running Altid server
+//
+//	func TestJoin(t *testing.T) {
+//		ctl := controllertest.New()
+//		svc := &myservice{}
+//		if e := svc.Start(ctl); e != nil {
+//			t.Fatal(e)
+//		}
+//		ctl.AssertBuffer(t, "#altid")
+//		ctl.AssertContains(t, "#altid", controllertest.Title, "Welcome")
+//	}
+package controllertest
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/altid/libs/service/controller"
+	"github.com/altid/libs/service/internal/buffers"
+)
+
+// Kind is the type of output a writer produces
+type Kind string
+
+// Writer kinds, one for each of the controller.Controller writers
+const (
+	Main   Kind = "main"
+	Status Kind = "status"
+	Title  Kind = "title"
+	Side   Kind = "side"
+	Nav    Kind = "nav"
+	Feed   Kind = "feed"
+	Image  Kind = "image"
+	Error  Kind = "error"
+)
+
+// Controller records everything a service does to it
+// Buffers are tracked the same way as a real Controller, so double creates and writes to missing buffers return a controller.BufferError
+// It is safe for concurrent use
+type Controller struct {
+	mu      sync.Mutex
+	buffers *buffers.Registry
+	created []string
+	deleted []string
+	removed [][2]string
+	output  map[string]map[Kind]*bytes.Buffer
+	images  map[string]map[string]*bytes.Buffer
+	notify  []controller.Notification
+}
+
+// New returns a Controller with no buffers
+func New() *Controller {
+	return &Controller{
+		buffers: buffers.New(),
+		output:  make(map[string]map[Kind]*bytes.Buffer),
+		images:  make(map[string]map[string]*bytes.Buffer),
+	}
+}
+
+func (c *Controller) CreateBuffer(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.buffers.Create(name); e != nil {
+		return e
+	}
+	c.created = append(c.created, name)
+	return nil
+}
+
+func (c *Controller) DeleteBuffer(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.buffers.Delete(name); e != nil {
+		return e
+	}
+	c.deleted = append(c.deleted, name)
+	return nil
+}
+
+func (c *Controller) Remove(buffer, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removed = append(c.removed, [2]string{buffer, name})
+	return nil
+}
+
+func (c *Controller) Notification(buffer, from, msg string) error {
+	return c.Notify(&controller.Notification{
+		Buffer: buffer,
+		From:   from,
+		Msg:    msg,
+	})
+}
+
+func (c *Controller) Notify(n *controller.Notification) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notify = append(c.notify, *n)
+	return nil
+}
+
+func (c *Controller) HasBuffer(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buffers.Has(name)
+}
+
+func (c *Controller) Buffer(name string) (controller.Buffer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buffers.Get(name)
+}
+
+func (c *Controller) Buffers() []controller.Buffer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buffers.List()
+}
+
+func (c *Controller) RangeBuffers(fn func(controller.Buffer) bool) {
+	for _, b := range c.Buffers() {
+		if !fn(b) {
+			return
+		}
+	}
+}
+
+// MarkRead resets the unread count of buffer, as when a client opens it
+func (c *Controller) MarkRead(buffer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buffers.MarkRead(buffer)
+}
+
+// ErrorWriter output is recorded under the empty buffer name
+func (c *Controller) ErrorWriter() (controller.WriteCloser, error) {
+	return c.writer("", Error), nil
+}
+
+func (c *Controller) StatusWriter(buffer string) (controller.WriteCloser, error) {
+	return c.writer(buffer, Status), nil
+}
+
+func (c *Controller) SideWriter(buffer string) (controller.WriteCloser, error) {
+	return c.writer(buffer, Side), nil
+}
+
+func (c *Controller) NavWriter(buffer string) (controller.WriteCloser, error) {
+	return c.writer(buffer, Nav), nil
+}
+
+func (c *Controller) TitleWriter(buffer string) (controller.WriteCloser, error) {
+	return c.writer(buffer, Title), nil
+}
+
+func (c *Controller) MainWriter(buffer string) (controller.WriteCloser, error) {
+	return c.writer(buffer, Main), nil
+}
+
+func (c *Controller) FeedWriter(buffer string) (controller.WriteCloser, error) {
+	return c.writer(buffer, Feed), nil
+}
+
+// ImageWriter output is recorded both under the Image kind for the buffer, and by name for Image
+func (c *Controller) ImageWriter(buffer, name string) (controller.WriteCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.images[buffer]; !ok {
+		c.images[buffer] = make(map[string]*bytes.Buffer)
+	}
+	c.images[buffer][name] = &bytes.Buffer{}
+	return &writer{c: c, buffer: buffer, kind: Image, image: name}, nil
+}
+
+// Created returns the names passed to CreateBuffer, in order
+func (c *Controller) Created() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.created...)
+}
+
+// Deleted returns the names passed to DeleteBuffer, in order
+func (c *Controller) Deleted() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.deleted...)
+}
+
+// Removed returns the buffer and name pairs passed to Remove, in order
+func (c *Controller) Removed() [][2]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][2]string(nil), c.removed...)
+}
+
+// Notifications returns all recorded notifications, in order
+func (c *Controller) Notifications() []controller.Notification {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]controller.Notification(nil), c.notify...)
+}
+
+// Output returns everything written to the buffer by writers of the given kind
+func (c *Controller) Output(buffer string, kind Kind) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if k, ok := c.output[buffer]; ok {
+		if b, ok := k[kind]; ok {
+			return b.String()
+		}
+	}
+	return ""
+}
+
+// Image returns the contents written to the named image in buffer
+func (c *Controller) Image(buffer, name string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if imgs, ok := c.images[buffer]; ok {
+		if b, ok := imgs[name]; ok {
+			return append([]byte(nil), b.Bytes()...)
+		}
+	}
+	return nil
+}
+
+// Reset clears all recorded state
+func (c *Controller) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buffers = buffers.New()
+	c.output = make(map[string]map[Kind]*bytes.Buffer)
+	c.images = make(map[string]map[string]*bytes.Buffer)
+	c.created = nil
+	c.deleted = nil
+	c.removed = nil
+	c.notify = nil
+}
+
+// AssertBuffer fails the test if buffer does not currently exist
+func (c *Controller) AssertBuffer(t testing.TB, buffer string) {
+	t.Helper()
+	if !c.HasBuffer(buffer) {
+		t.Errorf("expected buffer %q to exist, have %q", buffer, c.names())
+	}
+}
+
+// AssertNoBuffer fails the test if buffer currently exists
+func (c *Controller) AssertNoBuffer(t testing.TB, buffer string) {
+	t.Helper()
+	if c.HasBuffer(buffer) {
+		t.Errorf("expected buffer %q to not exist", buffer)
+	}
+}
+
+// AssertOutput fails the test if the output of kind in buffer is not exactly want
+func (c *Controller) AssertOutput(t testing.TB, buffer string, kind Kind, want string) {
+	t.Helper()
+	if have := c.Output(buffer, kind); have != want {
+		t.Errorf("%s output of %q: have %q, want %q", kind, buffer, have, want)
+	}
+}
+
+// AssertContains fails the test if the output of kind in buffer does not contain want
+func (c *Controller) AssertContains(t testing.TB, buffer string, kind Kind, want string) {
+	t.Helper()
+	if have := c.Output(buffer, kind); !strings.Contains(have, want) {
+		t.Errorf("%s output of %q: %q does not contain %q", kind, buffer, have, want)
+	}
+}
+
+// AssertNotified fails the test if no notification matching buffer, from and msg was recorded
+// Urgency, timeout and actions are not compared; inspect Notifications for those
+func (c *Controller) AssertNotified(t testing.TB, buffer, from, msg string) {
+	t.Helper()
+	for _, n := range c.Notifications() {
+		if n.Buffer == buffer && n.From == from && n.Msg == msg {
+			return
+		}
+	}
+	t.Errorf("expected notification from %q in %q: %q, have %+v", from, buffer, msg, c.Notifications())
+}
+
+func (c *Controller) names() []string {
+	var names []string
+	for _, b := range c.Buffers() {
+		names = append(names, b.Name)
+	}
+	return names
+}
+
+func (c *Controller) writer(buffer string, kind Kind) *writer {
+	return &writer{c: c, buffer: buffer, kind: kind}
+}
+
+// Must be called with the lock held
+func (c *Controller) buf(buffer string, kind Kind) *bytes.Buffer {
+	if _, ok := c.output[buffer]; !ok {
+		c.output[buffer] = make(map[Kind]*bytes.Buffer)
+	}
+	if _, ok := c.output[buffer][kind]; !ok {
+		c.output[buffer][kind] = &bytes.Buffer{}
+	}
+	return c.output[buffer][kind]
+}
+
+type writer struct {
+	c      *Controller
+	buffer string
+	kind   Kind
+	image  string
+	closed bool
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+	if w.closed {
+		return 0, errors.New("write on closed writer")
+	}
+	if w.kind != Error {
+		if e := w.c.buffers.Write("write "+string(w.kind), w.buffer, w.kind == Main || w.kind == Feed); e != nil {
+			return 0, e
+		}
+	}
+	if w.kind == Title {
+		w.c.buffers.SetTitle(w.buffer, strings.TrimSpace(string(b)))
+	}
+	if w.kind == Image {
+		if img, ok := w.c.images[w.buffer][w.image]; ok {
+			img.Write(b)
+		}
+	}
+	return w.c.buf(w.buffer, w.kind).Write(b)
+}
+
+func (w *writer) Close() error {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+	w.closed = true
+	return nil
+}
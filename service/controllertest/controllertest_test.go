@@ -0,0 +1,75 @@
+package controllertest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/altid/libs/service/controller"
+)
+
+var _ controller.Controller = (*Controller)(nil)
+
+func TestController(t *testing.T) {
+	c := New()
+	c.CreateBuffer("#altid")
+	c.CreateBuffer("#gone")
+	c.DeleteBuffer("#gone")
+
+	c.AssertBuffer(t, "#altid")
+	c.AssertNoBuffer(t, "#gone")
+
+	mw, _ := c.MainWriter("#altid")
+	mw.Write([]byte("hello "))
+	mw.Write([]byte("world"))
+	mw.Close()
+	if _, err := mw.Write([]byte("!")); err == nil {
+		t.Error("write succeeded on closed writer")
+	}
+
+	c.AssertOutput(t, "#altid", Main, "hello world")
+	c.AssertOutput(t, "#altid", Title, "")
+
+	iw, _ := c.ImageWriter("#altid", "cat.png")
+	iw.Write([]byte("meow"))
+	if string(c.Image("#altid", "cat.png")) != "meow" {
+		t.Error("unable to record image")
+	}
+	c.AssertContains(t, "#altid", Image, "meow")
+
+	ew, _ := c.ErrorWriter()
+	ew.Write([]byte("oops"))
+	c.AssertOutput(t, "", Error, "oops")
+
+	c.Notification("#altid", "halfwit", "ping")
+	c.AssertNotified(t, "#altid", "halfwit", "ping")
+
+	c.Reset()
+	c.AssertNoBuffer(t, "#altid")
+	if len(c.Created()) != 0 || len(c.Notifications()) != 0 {
+		t.Error("unable to reset controller")
+	}
+}
+
+func TestControllerBuffers(t *testing.T) {
+	c := New()
+	c.CreateBuffer("#altid")
+	if e := c.CreateBuffer("#altid"); !errors.Is(e, controller.ErrBufferExists) {
+		t.Errorf("expected ErrBufferExists, have %v", e)
+	}
+
+	tw, _ := c.TitleWriter("#altid")
+	tw.Write([]byte("Altid chat\n"))
+	mw, _ := c.MainWriter("#altid")
+	mw.Write([]byte("hello"))
+	if b, _ := c.Buffer("#altid"); b.Title != "Altid chat" || b.Unread != 1 {
+		t.Errorf("incorrect metadata %+v", b)
+	}
+
+	c.DeleteBuffer("#altid")
+	if _, e := mw.Write([]byte("hello")); !errors.Is(e, controller.ErrNoBuffer) {
+		t.Errorf("expected ErrNoBuffer writing to a deleted buffer, have %v", e)
+	}
+	if len(c.Buffers()) != 0 {
+		t.Error("deleted buffer still listed")
+	}
+}
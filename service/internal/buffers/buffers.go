@@ -0,0 +1,123 @@
+package buffers
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/altid/libs/service/controller"
+)
+
+// Registry tracks the buffers a service has created, and is safe for concurrent use
+type Registry struct {
+	mu   sync.RWMutex
+	bufs map[string]*controller.Buffer
+	now  func() time.Time
+}
+
+func New() *Registry {
+	return &Registry{
+		bufs: make(map[string]*controller.Buffer),
+		now:  time.Now,
+	}
+}
+
+// Create adds name to the registry, returning a BufferError if it already exists
+func (r *Registry) Create(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.bufs[name]; ok {
+		return &controller.BufferError{Op: "create", Name: name, Err: controller.ErrBufferExists}
+	}
+	now := r.now()
+	r.bufs[name] = &controller.Buffer{
+		Name:     name,
+		Created:  now,
+		Activity: now,
+	}
+	return nil
+}
+
+// Delete removes name from the registry, returning a BufferError if it does not exist
+func (r *Registry) Delete(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.bufs[name]; !ok {
+		return &controller.BufferError{Op: "delete", Name: name, Err: controller.ErrNoBuffer}
+	}
+	delete(r.bufs, name)
+	return nil
+}
+
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.bufs[name]
+	return ok
+}
+
+// Get returns a copy of the named buffer's metadata
+func (r *Registry) Get(name string) (controller.Buffer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if b, ok := r.bufs[name]; ok {
+		return *b, true
+	}
+	return controller.Buffer{}, false
+}
+
+// List returns a copy of every buffer's metadata, sorted by name
+func (r *Registry) List() []controller.Buffer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]controller.Buffer, 0, len(r.bufs))
+	for _, b := range r.bufs {
+		list = append(list, *b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Range calls fn for each buffer in name order, stopping if fn returns false
+// It works on a snapshot, so fn is free to create and delete buffers
+func (r *Registry) Range(fn func(controller.Buffer) bool) {
+	for _, b := range r.List() {
+		if !fn(b) {
+			return
+		}
+	}
+}
+
+// Write records output of op to the named buffer, returning a BufferError if it does not exist
+// Output that a user reads, such as main and feed, bumps the activity time and unread count
+func (r *Registry) Write(op, name string, unread bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.bufs[name]
+	if !ok {
+		return &controller.BufferError{Op: op, Name: name, Err: controller.ErrNoBuffer}
+	}
+	if unread {
+		b.Activity = r.now()
+		b.Unread++
+	}
+	return nil
+}
+
+// SetTitle records the title last written to the named buffer
+func (r *Registry) SetTitle(name, title string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.bufs[name]; ok {
+		b.Title = title
+	}
+}
+
+// MarkRead resets the unread count of the named buffer
+func (r *Registry) MarkRead(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.bufs[name]; ok {
+		b.Unread = 0
+	}
+}
@@ -0,0 +1,60 @@
+package buffers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/altid/libs/service/controller"
+)
+
+func TestRegistry(t *testing.T) {
+	r := New()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	if e := r.Create("#b"); e != nil {
+		t.Fatal(e)
+	}
+	if e := r.Create("#a"); e != nil {
+		t.Fatal(e)
+	}
+	if e := r.Create("#a"); !errors.Is(e, controller.ErrBufferExists) {
+		t.Errorf("expected ErrBufferExists, have %v", e)
+	}
+
+	now = now.Add(time.Minute)
+	r.Write("write main", "#a", true)
+	r.Write("write main", "#a", true)
+	r.Write("write status", "#a", false)
+	r.SetTitle("#a", "hello")
+
+	a, ok := r.Get("#a")
+	if !ok || a.Unread != 2 || a.Title != "hello" || !a.Activity.Equal(now) || a.Created.Equal(now) {
+		t.Errorf("incorrect metadata %+v", a)
+	}
+
+	r.MarkRead("#a")
+	if a, _ := r.Get("#a"); a.Unread != 0 {
+		t.Error("unable to mark buffer read")
+	}
+
+	var names []string
+	r.Range(func(b controller.Buffer) bool {
+		names = append(names, b.Name)
+		// Make sure we don't deadlock modifying the registry while ranging
+		r.Delete(b.Name)
+		return true
+	})
+	if len(names) != 2 || names[0] != "#a" || names[1] != "#b" {
+		t.Errorf("incorrect range order %q", names)
+	}
+
+	var be *controller.BufferError
+	if e := r.Write("write main", "#a", true); !errors.As(e, &be) || be.Op != "write main" || !errors.Is(e, controller.ErrNoBuffer) {
+		t.Errorf("expected a BufferError for a deleted buffer, have %v", e)
+	}
+	if e := r.Delete("#a"); !errors.Is(e, controller.ErrNoBuffer) {
+		t.Errorf("expected ErrNoBuffer, have %v", e)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"github.com/altid/libs/service/commander"
 	"github.com/altid/libs/service/internal/parse"
-	"html/template"
 	"io"
 	"strings"
 )
@@ -15,9 +14,6 @@ type Command struct {
 	CtrlDataCommand func() []byte
 }
 
-const commandTemplate = `{{range .}}	{{.Name}}{{if .Alias}}{{range .Alias}}|{{.}}{{end}}{{end}}{{if .Args}}	{{range .Args}}{{.}} {{end}}{{end}}{{if .Description}}	# {{.Description}}{{end}}
-{{end}}`
-
 // FindCommands within a byte array
 // It returns an error if it encounters malformed input
 func (c *Command) FindCommands(b []byte) ([]*commander.Command, error) {
@@ -27,13 +23,14 @@ func (c *Command) FindCommands(b []byte) ([]*commander.Command, error) {
 		return nil, err
 	}
 	for _, comm := range cl {
-		if comm.Heading < 0 {
+		if _, ok := comm.Heading.Group(); !ok && comm.Heading != commander.UnknownGroup {
 			return nil, fmt.Errorf("unable to find a heading for %s", comm.Name)
 		}
 		c := &commander.Command{
 			Name:        comm.Name,
 			Description: comm.Description,
-			Heading:     commander.ComGroup(comm.Heading),
+			Heading:     comm.Heading,
+			HeadingName: comm.HeadingName,
 			Args:        comm.Args,
 			Alias:       comm.Alias,
 			From:        comm.From,
@@ -51,14 +48,36 @@ func (c *Command) FromBytes(input []byte) (*commander.Command, error) {
 
 // FromString returns a partially filled command
 // It will have a Heading type of DefaultGroup
+// Arguments may be quoted, see parse.Split, except for input which is text for the service rather than arguments
 func (c *Command) FromString(input string) (*commander.Command, error) {
-	name, from, args, err := parse.ParseCmd(input)
+	name, from, raw, err := parse.ParseCmdRaw(input)
 	if err != nil {
 		return nil, err
 	}
-	if from != "" && len(args) < 1 {
-		args = strings.Fields(from)
-		from = ""
+	switch {
+	case name == "input":
+		if parse.BareArg(input, from, raw) {
+			raw, from = from, ""
+		}
+		return &commander.Command{
+			Name:    name,
+			From:    from,
+			Args:    strings.Fields(raw),
+			Heading: commander.DefaultGroup,
+		}, nil
+	// Completion needs the line exactly as typed, trailing space and all
+	case name == "complete":
+		return &commander.Command{
+			Name:    name,
+			From:    strings.TrimSpace(from),
+			Args:    []string{raw},
+			Heading: commander.DefaultGroup,
+		}, nil
+	}
+
+	name, from, args, err := parse.ParseCmd(input)
+	if err != nil {
+		return nil, err
 	}
 	comm := &commander.Command{
 		Name:    name,
@@ -69,78 +88,41 @@ func (c *Command) FromString(input string) (*commander.Command, error) {
 	return comm, nil
 }
 
+// WriteCommands writes cmdlist in the format of the ctl file, see parse.WriteCtlFile
 func (c *Command) WriteCommands(cmdlist []*commander.Command, to io.Writer) error {
-	var last int
-	curr := cmdlist[0].Heading
-	tp := template.Must(template.New("entry").Parse(commandTemplate))
-	for n, comm := range cmdlist {
-		// 0, 0 and comm.Heading != curr; we want to set a heading
-		if comm.Heading != curr {
-			cmdHeading(to, curr)
-			for j, subcomm := range cmdlist[last:] {
-				if subcomm.Heading != comm.Heading {
-					if n+j > last {
-						if e := tp.Execute(to, cmdlist[last:n+j]); e != nil {
-							return e
-						}
-						last = n + j
-					}
-					break
-				}
-			}
-			curr = comm.Heading
-		}
-	}
-	// We have one Grouping remaining, print
-	if last < len(cmdlist) {
-		cmdHeading(to, cmdlist[last].Heading)
-		if e := tp.Execute(to, cmdlist[last:]); e != nil {
-			return e
-		}
-	}
-	return nil
+	return parse.WriteCtlFile(to, cmdlist)
 }
 
-// So for this, we want to parse out a proper cmd - each arg can have spaces if it's wrapped in \" \"
+// FindCommand parses cmd, returning the matching command from cmdlist with its arguments
+// Each arg can have spaces if it's quoted, see parse.Split, and a RestParam takes the rest of the line as written
 func (c *Command) FindCommand(cmd string, cmdlist []*commander.Command) (*commander.Command, error) {
-	name, from, args, err := parse.ParseCmd(cmd)
+	name, _, _, err := parse.ParseCmdRaw(cmd)
 	if err != nil {
 		return nil, err
 	}
-	for _, comm := range cmdlist {
-
-		if comm.Name == name {
-			return newFrom(comm, from, args)
-		}
-		for _, alias := range comm.Alias {
-			if alias == name {
-				return newFrom(comm, from, args)
-			}
-		}
+	comm := lookup(name, cmdlist)
+	if comm == nil {
+		return nil, errors.New("command not supported")
 	}
-	return nil, errors.New("command not supported")
-}
-
-func cmdHeading(to io.Writer, heading commander.ComGroup) {
-	switch heading {
-	case commander.ActionGroup:
-		to.Write([]byte("emotes:\n"))
-	case commander.DefaultGroup:
-		to.Write([]byte("general:\n"))
-	case commander.MediaGroup:
-		to.Write([]byte("media:\n"))
-	case commander.ServiceGroup:
-		to.Write([]byte("service:\n"))
+	_, from, args, err := parse.ParseCmdN(cmd, comm.MaxArgs())
+	if err != nil {
+		return nil, err
 	}
+	return newFrom(comm, from, args)
 }
 
 func newFrom(comm *commander.Command, from string, args []string) (*commander.Command, error) {
+	args, err := comm.Validate(args)
+	if err != nil {
+		return nil, err
+	}
 	if comm.Heading == commander.ServiceGroup {
 		c := &commander.Command{
 			Name:        comm.Name,
 			Description: comm.Description,
 			Heading:     commander.ServiceGroup,
 			Args:        args,
+			Params:      comm.Params,
 		}
 		return c, nil
 	}
@@ -148,9 +130,11 @@ func newFrom(comm *commander.Command, from string, args []string) (*commander.Co
 		Name:        comm.Name,
 		Description: comm.Description,
 		Heading:     comm.Heading,
+		HeadingName: comm.HeadingName,
 		Args:        args,
 		Alias:       comm.Alias,
 		From:        from,
+		Params:      comm.Params,
 	}
 	return c, nil
 }
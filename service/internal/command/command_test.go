@@ -1 +1,149 @@
 package command
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/internal/parse"
+)
+
+func TestWriteCommandsParams(t *testing.T) {
+	cmds := []*commander.Command{
+		{
+			Name:        "kick",
+			Heading:     commander.ActionGroup,
+			Description: "Remove a user",
+			Params: []commander.Param{
+				{Name: "nick"},
+				{Name: "reason", Type: commander.RestParam, Optional: true},
+			},
+		},
+	}
+	var b bytes.Buffer
+	c := &Command{}
+	if e := c.WriteCommands(cmds, &b); e != nil {
+		t.Fatal(e)
+	}
+	if b.String() != "emotes:\n\tkick\t<nick> <[reason:line]>\t# Remove a user\n" {
+		t.Errorf("unexpected output %q", b.String())
+	}
+
+	found, err := c.FindCommands(b.Bytes())
+	if err != nil || len(found) != 1 || found[0].Name != "kick" {
+		t.Fatalf("unable to read back commands: %v", err)
+	}
+
+	if _, err := c.FindCommand("kick #altid", cmds); err != nil {
+		t.Error(err)
+	}
+	var ue *commander.UsageError
+	if _, err := c.FindCommand("kick", cmds); !errors.As(err, &ue) {
+		t.Errorf("expected a UsageError, have %v", err)
+	}
+}
+
+func TestQuotedRoundTrip(t *testing.T) {
+	c := &Command{}
+	for _, want := range []*commander.Command{
+		{Name: "topic", From: "#chan", Args: []string{"hello world"}},
+		{Name: "msg", From: "#chan", Args: []string{"bob", `say "hi"`, `C:\dir`, "it's", ""}},
+		{Name: "open", Args: []string{"#altid"}},
+		{Name: "open", Args: []string{"#two words", "tab\there"}},
+	} {
+		have, err := c.FromString(want.String())
+		if err != nil {
+			t.Errorf("%q: %v", want.String(), err)
+			continue
+		}
+		if have.Name != want.Name || have.From != want.From || !reflect.DeepEqual(have.Args, want.Args) {
+			t.Errorf("%q: have %q %q %q", want.String(), have.Name, have.From, have.Args)
+		}
+	}
+}
+
+func TestFromStringQuotes(t *testing.T) {
+	c := &Command{}
+	cmd, err := c.FromString("input #chan\n\tdon't \"panic\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"don't", `"panic"`}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("input should not be unquoted, have %q", cmd.Args)
+	}
+
+	if cmd, err = c.FromString("input it's"); err != nil || cmd.From != "" || cmd.Args[0] != "it's" {
+		t.Errorf("input without a buffer: have %v, %v", cmd, err)
+	}
+
+	quit := (&commander.Command{Name: "quit", From: "#chan"}).String()
+	if cmd, err = c.FromString(quit); err != nil || cmd.From != "#chan" || len(cmd.Args) != 0 {
+		t.Errorf("%q: have %v, %v", quit, cmd, err)
+	}
+
+	_, err = c.FromString("topic #chan\n\t\"hello world")
+	var qe *parse.QuoteError
+	if !errors.As(err, &qe) || qe.Offset != 13 {
+		t.Errorf("expected QuoteError at offset 13, have %v", err)
+	}
+}
+
+func TestFindCommandRest(t *testing.T) {
+	c := &Command{}
+	cmdlist := []*commander.Command{{
+		Name:   "kick",
+		Params: []commander.Param{{Name: "nick"}, {Name: "reason", Type: commander.RestParam, Optional: true}},
+	}}
+	cmd, err := c.FindCommand("kick #chan\n\tbob  you're \"out\" ", cmdlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"bob", `you're "out"`}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("have %q, want %q", cmd.Args, want)
+	}
+}
+
+func TestWriteCommandsGroups(t *testing.T) {
+	admin := commander.MustRegisterGroup("admin", "Administration", 50)
+	cmds := []*commander.Command{
+		{Name: "restart", Heading: commander.ServiceGroup, Description: "Restart the service"},
+		{Name: "ban", Heading: admin, Args: []string{"<nick>"}},
+		{Name: "open", Heading: commander.DefaultGroup, Description: "Open a buffer"},
+	}
+	sort.Sort(commander.CmdList(cmds))
+	var b bytes.Buffer
+	c := &Command{}
+	if e := c.WriteCommands(cmds, &b); e != nil {
+		t.Fatal(e)
+	}
+	want := "general:\n\topen\t# Open a buffer\nadmin:\n\tban\t<nick>\nservice:\n\trestart\t# Restart the service\n"
+	if b.String() != want {
+		t.Errorf("have %q, want %q", b.String(), want)
+	}
+
+	// A client may not know the groups of a service, so unknown headings are kept by name, without being registered
+	registered := len(commander.Groups())
+	found, err := c.FindCommands(append(b.Bytes(), "wizardry:\n\tsummon\t# Call forth a familiar\n"...))
+	if err != nil || len(found) != 4 {
+		t.Fatalf("unable to read back commands: %v", err)
+	}
+	for i, want := range []string{"general", "admin", "service", "wizardry"} {
+		have := found[i].Heading.String()
+		if found[i].Heading == commander.UnknownGroup {
+			have = found[i].HeadingName
+		}
+		if have != want {
+			t.Errorf("%s: have heading %q, want %q", found[i].Name, have, want)
+		}
+	}
+	if len(commander.Groups()) != registered {
+		t.Error("reading an unknown heading registered it")
+	}
+
+	if e := c.WriteCommands([]*commander.Command{{Name: "lost", Heading: 9001}}, &b); e == nil {
+		t.Error("expected error writing an unregistered group")
+	}
+}
@@ -0,0 +1,149 @@
+package command
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/internal/parse"
+)
+
+// Lower ranks sort first
+const (
+	rankExact = iota
+	rankPrefix
+	rankFold
+)
+
+type ranked struct {
+	commander.Candidate
+	rank  int
+	alias bool
+}
+
+// Complete returns candidates for the last word of line, best first
+// The line is split into words as parse.Split does, so a quoted argument counts as one word
+// Matches with the same case rank over case-insensitive ones, names over aliases, and shorter over longer
+func (c *Command) Complete(line, buffer string, cmdlist []*commander.Command, src commander.Source) []commander.Candidate {
+	words := splitLine(line)
+	prefix := words[len(words)-1]
+
+	var found []ranked
+	add := func(text, desc string, alias bool) {
+		if r, ok := rank(text, prefix); ok {
+			found = append(found, ranked{commander.Candidate{Text: text, Description: desc}, r, alias})
+		}
+	}
+
+	if len(words) == 1 {
+		for _, cmd := range cmdlist {
+			add(cmd.Name, cmd.Description, false)
+			for _, alias := range cmd.Alias {
+				add(alias, "alias of "+cmd.Name, true)
+			}
+		}
+		return sorted(found)
+	}
+
+	cmd := lookup(words[0], cmdlist)
+	if cmd == nil {
+		return nil
+	}
+	p, ok := param(cmd, len(words)-2)
+	if !ok {
+		return nil
+	}
+	values := p.Enum
+	if p.Type != commander.EnumParam && src != nil {
+		values = src(buffer, cmd, p, prefix)
+	}
+	for _, v := range values {
+		add(v, p.String(), false)
+	}
+	return sorted(found)
+}
+
+// splitLine splits line as parse.Split does, ending with the word being typed
+// That word may be empty after a trailing space, or still inside a quote which hasn't been closed
+func splitLine(line string) []string {
+	// Nothing typed can hold a NUL, so it marks where the line ends
+	const cursor = "\x00"
+	words, err := parse.Split(line + cursor)
+	var qe *parse.QuoteError
+	if errors.As(err, &qe) {
+		words, err = parse.Split(line + cursor + string(qe.Quote))
+	}
+	if err != nil || len(words) == 0 {
+		return []string{""}
+	}
+	words[len(words)-1] = strings.TrimSuffix(words[len(words)-1], cursor)
+	return words
+}
+
+func lookup(name string, cmdlist []*commander.Command) *commander.Command {
+	for _, cmd := range cmdlist {
+		if cmd.Name == name {
+			return cmd
+		}
+		for _, alias := range cmd.Alias {
+			if alias == name {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+// param returns the Param filled by argument n, counting from zero
+func param(cmd *commander.Command, n int) (commander.Param, bool) {
+	if len(cmd.Params) == 0 {
+		return commander.Param{}, false
+	}
+	if n < len(cmd.Params) {
+		return cmd.Params[n], true
+	}
+	last := cmd.Params[len(cmd.Params)-1]
+	if last.Variadic || last.Type == commander.RestParam {
+		return last, true
+	}
+	return commander.Param{}, false
+}
+
+func rank(text, prefix string) (int, bool) {
+	switch {
+	case text == prefix:
+		return rankExact, true
+	case strings.HasPrefix(text, prefix):
+		return rankPrefix, true
+	case strings.HasPrefix(strings.ToLower(text), strings.ToLower(prefix)):
+		return rankFold, true
+	}
+	return 0, false
+}
+
+func sorted(found []ranked) []commander.Candidate {
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		switch {
+		case a.rank != b.rank:
+			return a.rank < b.rank
+		case a.alias != b.alias:
+			return !a.alias
+		case len(a.Text) != len(b.Text):
+			return len(a.Text) < len(b.Text)
+		}
+		return a.Text < b.Text
+	})
+
+	var out []commander.Candidate
+	seen := make(map[string]bool)
+	for _, f := range found {
+		if seen[f.Text] {
+			continue
+		}
+		seen[f.Text] = true
+		out = append(out, f.Candidate)
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/altid/libs/service/commander"
+)
+
+func TestComplete(t *testing.T) {
+	cmds := []*commander.Command{
+		{Name: "kick", Alias: []string{"k"}, Params: []commander.Param{{Name: "nick"}, {Name: "reason", Type: commander.RestParam}}},
+		{Name: "Kill", Description: "Kill a user"},
+		{Name: "mode", Params: []commander.Param{{Name: "mode", Type: commander.EnumParam, Enum: []string{"on", "off", "auto"}}}},
+		{Name: "msg", Alias: []string{"m", "message"}},
+	}
+	nicks := func(buffer string, cmd *commander.Command, p commander.Param, prefix string) []string {
+		if buffer != "#altid" || cmd.Name != "kick" {
+			t.Errorf("source called with %q and %q", buffer, cmd.Name)
+		}
+		return []string{"halfwit", "Hal", "bob"}
+	}
+
+	c := &Command{}
+	for _, tc := range []struct {
+		line string
+		want []string
+	}{
+		{"k", []string{"k", "kick", "Kill"}},
+		{"m", []string{"m", "msg", "mode", "message"}},
+		{"kick ha", []string{"halfwit", "Hal"}},
+		{"k ", []string{"Hal", "bob", "halfwit"}},
+		{"kick bob ", []string{"Hal", "bob", "halfwit"}},
+		{"mode o", []string{"on", "off"}},
+		{"mode on ", nil},
+		{`kick "ha`, []string{"halfwit", "Hal"}},
+		{`kick 'ha`, []string{"halfwit", "Hal"}},
+		{`kick "bob smith" `, []string{"Hal", "bob", "halfwit"}},
+		{`kick "bob `, nil},
+		{`kick Hal\ `, nil},
+		{"nosuch ", nil},
+	} {
+		var have []string
+		for _, cand := range c.Complete(tc.line, "#altid", cmds, nicks) {
+			have = append(have, cand.Text)
+		}
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%q: have %q, want %q", tc.line, have, tc.want)
+		}
+	}
+}
+
+func TestFromComplete(t *testing.T) {
+	cmd, err := (&Command{}).FromString("complete #altid\n\tkick ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "complete" || cmd.From != "#altid" || len(cmd.Args) != 1 || cmd.Args[0] != "kick " {
+		t.Errorf("line not kept as typed, have %+v", cmd)
+	}
+}
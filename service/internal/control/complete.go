@@ -0,0 +1,45 @@
+package control
+
+import (
+	"strings"
+
+	"github.com/altid/libs/service/callback"
+	"github.com/altid/libs/service/commander"
+)
+
+// complete handles `complete <buffer>\n\t<line>`, sending the candidates back as a Result
+// Candidates come from the service's commands and the defaults, open buffers, enum values, and the service if it implements callback.Completer
+func (c *Control) complete(cmd *commander.Command) error {
+	if err := wantArgs(cmd, 1); err != nil {
+		return err
+	}
+	cmds := append([]*commander.Command(nil), c.cmdlist...)
+	for _, d := range commander.DefaultCommands {
+		if c.serviceCommand(d.Name) == nil {
+			cmds = append(cmds, d)
+		}
+	}
+
+	res := &commander.Result{Columns: []string{"text", "description"}}
+	for _, cand := range c.commander.Complete(cmd.Args[0], cmd.From, cmds, c.completions) {
+		if strings.ContainsAny(cand.Text, "\t\n\x00") {
+			continue
+		}
+		res.AddRow(cand.Text, strings.NewReplacer("\t", " ", "\n", " ", "\x00", "").Replace(cand.Description))
+	}
+	return c.sendResult(cmd, res)
+}
+
+// completions is the commander.Source for the service
+func (c *Control) completions(buffer string, cmd *commander.Command, p commander.Param, prefix string) []string {
+	var values []string
+	if p.Type == commander.BufferParam {
+		for _, b := range c.buffers.List() {
+			values = append(values, b.Name)
+		}
+	}
+	if cp, ok := c.cb.(callback.Completer); ok {
+		values = append(values, cp.Complete(buffer, cmd, p, prefix)...)
+	}
+	return values
+}
@@ -0,0 +1,44 @@
+package control
+
+import (
+	"io"
+	"testing"
+
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/controller"
+)
+
+type completeCallback struct {
+	*testCallback
+}
+
+func (cc *completeCallback) Complete(buffer string, cmd *commander.Command, p commander.Param, prefix string) []string {
+	return []string{"#alt-nick"}
+}
+
+func TestComplete(t *testing.T) {
+	cb := &completeCallback{&testCallback{started: make(chan controller.Controller)}}
+	ctl, server, msgs := testServer(t, cb.testCallback)
+	ctl.SetCallbacks(cb)
+	ctl.SetCommands([]*commander.Command{
+		{Name: "join", Heading: commander.ActionGroup, Params: []commander.Param{{Name: "channel", Type: commander.BufferParam}}},
+	})
+	go ctl.Listen()
+	c := <-cb.started
+	c.CreateBuffer("#altid")
+	<-msgs
+
+	io.WriteString(server, "complete #altid\n\tjoin #alt\x00")
+	want := "result #altid\n\tcommand complete\n\tcolumns text\tdescription\n\trow #altid\t<channel:buffer>\n\trow #alt-nick\t<channel:buffer>"
+	if msg := <-msgs; msg != want {
+		t.Errorf("have %q, want %q", msg, want)
+	}
+
+	// Default commands complete too
+	io.WriteString(server, "complete #altid\n\tcl\x00")
+	want = "result #altid\n\tcommand complete\n\tcolumns text\tdescription\n\trow close\tClose a buffer and return to the last opened previously"
+	if msg := <-msgs; msg != want {
+		t.Errorf("have %q, want %q", msg, want)
+	}
+	io.WriteString(server, "shutdown\x00")
+}
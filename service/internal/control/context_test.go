@@ -0,0 +1,66 @@
+package control
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/altid/libs/markup"
+	"github.com/altid/libs/service/callback"
+)
+
+type ctxCallback struct {
+	testCallback
+	handled chan context.Context
+	user    string
+}
+
+func (c *ctxCallback) ConnectContext(ctx context.Context, username string) error {
+	c.user, _ = callback.Username(ctx)
+	return nil
+}
+
+func (c *ctxCallback) HandleContext(ctx context.Context, path string, l *markup.Lexer) error {
+	c.handled <- ctx
+	<-ctx.Done()
+	return nil
+}
+
+func TestContextCallbacks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctl, server := newTestControl(t, ctx)
+	defer server.Close()
+	cb := &ctxCallback{handled: make(chan context.Context)}
+	ctl.SetCallbacks(cb)
+	ctl.SetHandlerTimeout(time.Hour)
+
+	for _, msg := range []string{"connect halfwit", "input #altid\n\thello"} {
+		cmd, _ := ctl.commander.FromString(msg)
+		if e := ctl.dispatch(ctx, cmd); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if cb.user != "halfwit" {
+		t.Errorf("ConnectContext not called with username, have %q", cb.user)
+	}
+
+	hctx := <-cb.handled
+	if u, _ := callback.Username(hctx); u != "halfwit" {
+		t.Errorf("incorrect username %q", u)
+	}
+	if b, _ := callback.Buffer(hctx); b != "#altid" {
+		t.Errorf("incorrect buffer %q", b)
+	}
+	if _, ok := hctx.Deadline(); !ok {
+		t.Error("handler context has no deadline")
+	}
+
+	cancel()
+	select {
+	case <-hctx.Done():
+	case <-time.After(time.Second):
+		t.Error("handler context not cancelled on shutdown")
+	}
+}
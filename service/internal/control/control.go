@@ -3,83 +3,175 @@ package control
 import (
 	"bytes"
 	"context"
-	"fmt"
-	"log"
-	"os"
+	"errors"
+	"log/slog"
 	"sort"
 	"sync"
+	"time"
 
-	"github.com/altid/libs/markup"
 	"github.com/altid/libs/service/callback"
 	"github.com/altid/libs/service/commander"
 	"github.com/altid/libs/service/controller"
+	"github.com/altid/libs/service/internal/buffers"
 	"github.com/altid/libs/service/internal/command"
+	"github.com/altid/libs/service/transport"
 )
 
 type Control struct {
-	l sync.Mutex
-	done chan bool
-	errs chan error
-	cmds chan *commander.Command
-	ctl *os.File
-	cb callback.Callback
-	ctx context.Context
+	l         sync.Mutex
+	done      chan bool
+	errs      chan error
+	cmds      chan *commander.Command
+	ctl       transport.Conn
+	cb        callback.Callback
+	ctx       context.Context
 	commander commander.Commander
-	cmdlist []*commander.Command
+	cmdlist   []*commander.Command
+	buffers   *buffers.Registry
+	reload    func() error
+	cancel    context.CancelFunc
+	el        sync.Mutex
+	exit      error
+	wl        sync.Mutex
+	writers   map[*prefix]struct{}
+	pool      *pool
+	jobs      *jobs
+	timeout   time.Duration
+	ul        sync.Mutex
+	user      string
+	logger    *slog.Logger
+}
+
+// ConnectService registers name with the Altid server through t, returning a Control for the service
+// If t is nil, transport.Default is used
+func ConnectService(ctx context.Context, name string, t transport.Transport) (*Control, error) {
+	if t == nil {
+		t = transport.Default()
+	}
+
+	conn, err := t.Dial(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The context is ours from the start, so a signal arriving before Listen still has something to cancel
+	ctx, cancel := context.WithCancel(ctx)
+	ctl := &Control{
+		cmds:    make(chan *commander.Command),
+		done:    make(chan bool, 1),
+		errs:    make(chan error),
+		ctx:     ctx,
+		cancel:  cancel,
+		ctl:     conn,
+		buffers: buffers.New(),
+		writers: make(map[*prefix]struct{}),
+		pool:    newPool(defaultWorkers, defaultQueue),
+		jobs:    newJobs(),
+		logger:  slog.Default(),
+	}
+
+	return ctl, nil
 }
 
 func (c *Control) Listen() error {
 	defer c.ctl.Close()
 
+	ctx := c.ctx
+	defer c.stop(nil)
+
 	c.commander = &command.Command{
-		SendCommand: c.sendCommand,
+		SendCommand:     c.sendCommand,
 		CtrlDataCommand: c.ctrlData,
 	}
 
 	go func(c *Control) {
-		c.cb.Start(c)
+		if s, ok := c.cb.(callback.ContextStarter); ok {
+			s.StartContext(ctx, c)
+		} else {
+			c.cb.Start(c)
+		}
 		c.done <- true
 	}(c)
 
+	go c.readCommands(ctx)
+
 	go func(c *Control) {
 		for cmd := range c.cmds {
-			log.Print(cmd.String())
-			if cmd.Name == "input" {
-				l := markup.NewLexer(cmd.ArgBytes())
-				c.cb.Handle(cmd.From, l)
-			} else {
-				if e := c.commander.Exec(cmd); e != nil {
-					// return this to our errors
-					log.Print(e)
-				}
+			c.logger.Debug("command", "command", cmd.Name, "buffer", cmd.From)
+			if e := c.dispatch(ctx, cmd); e != nil {
+				c.reportError(cmd, e)
 			}
 		}
 	}(c)
 
-	select {
-	case e := <-c.errs:
-		return e
-	case <- c.done:
-		return nil
+	for {
+		select {
+		case e := <-c.errs:
+			// A single bad message shouldn't take down the service
+			var me *MessageError
+			if errors.As(e, &me) {
+				c.logger.Warn("malformed message", "bytes", len(me.Msg), "err", me.Err)
+				continue
+			}
+			return e
+		case <-c.done:
+			return nil
+		case <-ctx.Done():
+			return c.exitErr(ctx)
+		}
 	}
-
 }
 
 func (c *Control) SetCallbacks(cb callback.Callback) {
 	c.cb = cb
 }
 
+// SetConcurrency limits how many input handlers run at once, and how much input may wait per buffer
+// Values less than one use the defaults
+func (c *Control) SetConcurrency(workers, queue int) {
+	c.pool = newPool(workers, queue)
+}
+
+// SetLogger sets the logger used for commands, writes and errors, slog.Default by default
+func (c *Control) SetLogger(l *slog.Logger) {
+	c.logger = l
+}
+
+// SetHandlerTimeout sets a deadline on the context passed to each callback.ContextHandler call
+// A zero duration leaves it without one
+func (c *Control) SetHandlerTimeout(d time.Duration) {
+	c.timeout = d
+}
+
+// SetCommands adds cmds to the commands the service offers
+// If any are Async, commander.JobCommands are offered as well
 func (c *Control) SetCommands(cmds []*commander.Command) {
 	c.cmdlist = append(c.cmdlist, cmds...)
+	for _, cmd := range cmds {
+		if cmd.Async && c.serviceCommand("jobs") == nil {
+			c.cmdlist = append(c.cmdlist, commander.JobCommands...)
+			break
+		}
+	}
 	sort.Sort(commander.CmdList(c.cmdlist))
 }
 
 func (c *Control) CreateBuffer(name string) error {
-	return cmd(c, "create " + name)
+	if e := c.buffers.Create(name); e != nil {
+		return e
+	}
+	if e := cmd(c, "create "+name); e != nil {
+		c.buffers.Delete(name)
+		return e
+	}
+	return nil
 }
 
 func (c *Control) DeleteBuffer(name string) error {
-	return cmd(c, "delete " + name)
+	if e := c.buffers.Delete(name); e != nil {
+		return e
+	}
+	return cmd(c, "delete "+name)
 }
 
 // TODO: Research usage
@@ -87,9 +179,23 @@ func (c *Control) Remove(string, string) error {
 	return nil
 }
 
-// TODO: Research usage
-func (c *Control) Notification(string, string, string) error {
-	return nil
+func (c *Control) Notification(buffer, from, msg string) error {
+	return c.Notify(&controller.Notification{
+		Buffer: buffer,
+		From:   from,
+		Msg:    msg,
+	})
+}
+
+func (c *Control) Notify(n *controller.Notification) error {
+	msg, err := notifyMsg(n)
+	if err != nil {
+		return err
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	return writeMsg(c.ctl, msg)
 }
 
 func (c *Control) ErrorWriter() (controller.WriteCloser, error) {
@@ -112,7 +218,7 @@ func (c *Control) TitleWriter(buffer string) (controller.WriteCloser, error) {
 	return newPrefix(c, titleFmt, buffer)
 }
 
-func (c *Control) ImageWriter (buffer string, name string) (controller.WriteCloser, error) {
+func (c *Control) ImageWriter(buffer string, name string) (controller.WriteCloser, error) {
 	return newPrefix(c, imageFmt, buffer, name)
 }
 
@@ -124,30 +230,49 @@ func (c *Control) FeedWriter(buffer string) (controller.WriteCloser, error) {
 	return newPrefix(c, feedFmt, buffer)
 }
 
-// TODO: We don't really need this anymore
-func (c *Control) HasBuffer(string) bool {
-	return false
+func (c *Control) HasBuffer(name string) bool {
+	return c.buffers.Has(name)
+}
+
+func (c *Control) Buffer(name string) (controller.Buffer, bool) {
+	return c.buffers.Get(name)
+}
+
+func (c *Control) Buffers() []controller.Buffer {
+	return c.buffers.List()
+}
+
+func (c *Control) RangeBuffers(fn func(controller.Buffer) bool) {
+	c.buffers.Range(fn)
 }
 
 func (c *Control) sendCommand(cmd *commander.Command) error {
 	switch cmd.Name {
 	case "shutdown":
-		c.ctx.Done()
+		c.shutdown()
 		return nil
 	case "reload":
+		return c.doReload()
 	case "restart":
+		c.restart()
+		return nil
+	case "dump":
+		c.dump()
 		return nil
 	}
 
-	_, err := c.ctl.Write(cmd.Bytes())
-	return err
+	c.l.Lock()
+	defer c.l.Unlock()
+	return writeMsg(c.ctl, cmd.Bytes())
 }
 
 func (c *Control) ctrlData() (b []byte) {
 	c.l.Lock()
 	defer c.l.Unlock()
 	cw := bytes.NewBuffer(b)
-	c.commander.WriteCommands(c.cmdlist, cw)
+	if e := c.commander.WriteCommands(c.cmdlist, cw); e != nil {
+		c.logger.Error("unable to write commands", "err", e)
+	}
 
 	return cw.Bytes()
 }
@@ -155,6 +280,5 @@ func (c *Control) ctrlData() (b []byte) {
 func cmd(c *Control, cmd string) error {
 	c.l.Lock()
 	defer c.l.Unlock()
-	_, err := fmt.Fprintf(c.ctl, "%s\x00", cmd)
-	return err
+	return writeMsg(c.ctl, []byte(cmd))
 }
@@ -0,0 +1,153 @@
+package control
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/altid/libs/markup"
+	"github.com/altid/libs/service/callback"
+	"github.com/altid/libs/service/commander"
+)
+
+// dispatch hands a command read from the ctl to whichever part of the service handles it
+// Input is queued on the worker for its buffer, so a slow handler only holds up its own buffer
+// Default commands go to the matching optional callback if the service implements it, and to the commander otherwise
+func (c *Control) dispatch(ctx context.Context, cmd *commander.Command) error {
+	switch cmd.Name {
+	case "input":
+		return c.pool.submit(ctx, cmd.From, func() {
+			if e := c.handle(ctx, cmd); e != nil {
+				c.reportError(cmd, e)
+			}
+		})
+	case "connect":
+		if err := wantArgs(cmd, 1); err != nil {
+			return err
+		}
+		c.setUsername(cmd.Args[0])
+		if cc, ok := c.cb.(callback.ContextConnecter); ok {
+			return cc.ConnectContext(callback.WithUsername(ctx, cmd.Args[0]), cmd.Args[0])
+		}
+		return c.cb.Connect(cmd.Args[0])
+	case "disconnect":
+		if d, ok := c.cb.(callback.Disconnecter); ok {
+			if err := wantArgs(cmd, 1); err != nil {
+				return err
+			}
+			return d.Disconnect(cmd.Args[0])
+		}
+		return nil
+	case "open":
+		if err := wantArgs(cmd, 1); err != nil {
+			return err
+		}
+		// A client looking at a buffer has read it
+		c.buffers.MarkRead(cmd.Args[0])
+		if o, ok := c.cb.(callback.Opener); ok {
+			return o.Open(cmd.Args[0])
+		}
+	case "buffer":
+		if err := wantArgs(cmd, 1); err != nil {
+			return err
+		}
+		c.buffers.MarkRead(cmd.Args[0])
+		if s, ok := c.cb.(callback.Switcher); ok {
+			return s.Switch(cmd.Args[0])
+		}
+	case "close":
+		if cl, ok := c.cb.(callback.Closer); ok {
+			if err := wantArgs(cmd, 1); err != nil {
+				return err
+			}
+			return cl.Close(cmd.Args[0])
+		}
+	case "complete":
+		return c.complete(cmd)
+	case "jobs":
+		if c.async() {
+			return c.sendResult(cmd, c.jobs.result())
+		}
+	case "cancel":
+		if c.async() {
+			return c.cancelJob(cmd)
+		}
+	case "link":
+		if l, ok := c.cb.(callback.Linker); ok {
+			if err := wantArgs(cmd, 2); err != nil {
+				return err
+			}
+			return l.Link(cmd.Args[0], cmd.Args[1])
+		}
+	}
+
+	// Commands of the service's own may take a while, so like input they run on the worker for their buffer
+	// Async commands may take much longer, and run as a job
+	sc := c.serviceCommand(cmd.Name)
+	if sc != nil {
+		args, err := sc.Validate(cmd.Args)
+		if err != nil {
+			return err
+		}
+		cmd.Args = args
+	}
+	if sc != nil && sc.Async && c.async() {
+		run := *cmd
+		run.Name = sc.Name
+		c.startJob(ctx, &run)
+		return nil
+	}
+	if sc != nil && c.runs() {
+		run := *cmd
+		run.Name = sc.Name
+		return c.pool.submit(ctx, cmd.From, func() {
+			if e := c.run(ctx, &run); e != nil {
+				c.reportError(&run, e)
+			}
+		})
+	}
+
+	return c.commander.Exec(cmd)
+}
+
+func wantArgs(cmd *commander.Command, n int) error {
+	if len(cmd.Args) < n {
+		return fmt.Errorf("%s expects %d argument(s), have %d", cmd.Name, n, len(cmd.Args))
+	}
+	return nil
+}
+
+// handle passes input to the service, preferring callback.ContextHandler
+func (c *Control) handle(ctx context.Context, cmd *commander.Command) error {
+	l := markup.NewLexer(cmd.ArgBytes())
+	if h, ok := c.cb.(callback.ContextHandler); ok {
+		ctx, cancel := c.requestContext(ctx, cmd.From)
+		defer cancel()
+		return h.HandleContext(ctx, cmd.From, l)
+	}
+	return c.cb.Handle(cmd.From, l)
+}
+
+// requestContext returns a context for a single request from buffer
+// It carries the buffer and the last connected username, and the handler timeout if one is set
+func (c *Control) requestContext(ctx context.Context, buffer string) (context.Context, context.CancelFunc) {
+	ctx = callback.WithBuffer(ctx, buffer)
+	if u := c.username(); u != "" {
+		ctx = callback.WithUsername(ctx, u)
+	}
+	if c.timeout > 0 {
+		return context.WithTimeout(ctx, c.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (c *Control) setUsername(u string) {
+	c.ul.Lock()
+	defer c.ul.Unlock()
+	c.user = u
+}
+
+func (c *Control) username() string {
+	c.ul.Lock()
+	defer c.ul.Unlock()
+	return c.user
+}
@@ -0,0 +1,70 @@
+package control
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/altid/libs/service/commander"
+)
+
+type hookCallback struct {
+	testCallback
+	calls []string
+}
+
+func (h *hookCallback) Open(buffer string) error {
+	h.calls = append(h.calls, "open "+buffer)
+	return nil
+}
+func (h *hookCallback) Close(buffer string) error {
+	h.calls = append(h.calls, "close "+buffer)
+	return nil
+}
+func (h *hookCallback) Switch(buffer string) error {
+	h.calls = append(h.calls, "buffer "+buffer)
+	return nil
+}
+func (h *hookCallback) Link(current, buffer string) error {
+	h.calls = append(h.calls, "link "+current+" "+buffer)
+	return nil
+}
+func (h *hookCallback) Disconnect(username string) error {
+	h.calls = append(h.calls, "disconnect "+username)
+	return nil
+}
+
+func TestDispatchHooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctl, server := newTestControl(t, ctx)
+	defer server.Close()
+	cb := &hookCallback{}
+	ctl.SetCallbacks(cb)
+
+	ctl.buffers.Create("#foo")
+	ctl.buffers.Write("write main", "#foo", true)
+
+	for _, cmd := range []string{"open #foo", "buffer #foo", "close #foo", "link #foo #bar", "disconnect halfwit"} {
+		c, err := ctl.commander.FromString(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := ctl.dispatch(ctx, c); e != nil {
+			t.Errorf("%s: %v", cmd, e)
+		}
+	}
+
+	want := []string{"open #foo", "buffer #foo", "close #foo", "link #foo #bar", "disconnect halfwit"}
+	if !reflect.DeepEqual(cb.calls, want) {
+		t.Errorf("have %q, want %q", cb.calls, want)
+	}
+	if b, _ := ctl.buffers.Get("#foo"); b.Unread != 0 {
+		t.Error("opened buffer not marked read")
+	}
+
+	if e := ctl.dispatch(ctx, &commander.Command{Name: "link", Args: []string{"#foo"}}); e == nil {
+		t.Error("expected error for link with a single argument")
+	}
+}
@@ -0,0 +1,54 @@
+package control
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/altid/libs/service/callback"
+	"github.com/altid/libs/service/commander"
+)
+
+// CommandError is written to the error file when a command or input fails
+type CommandError struct {
+	Buffer  string
+	Command string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	if e.Buffer == "" {
+		return fmt.Sprintf("%s: %v", e.Command, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Buffer, e.Command, e.Err)
+}
+
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// reportError writes err to the client's error file, tagged with the buffer and command that caused it
+// A callback implementing callback.ErrorHandler may rewrite or suppress the message
+func (c *Control) reportError(cmd *commander.Command, err error) {
+	buffer := cmd.From
+	if h, ok := c.cb.(callback.ErrorHandler); ok {
+		err = h.HandleError(buffer, cmd, err)
+	} else {
+		err = &CommandError{
+			Buffer:  buffer,
+			Command: cmd.Name,
+			Err:     err,
+		}
+	}
+	if err == nil {
+		return
+	}
+
+	c.logger.Error("command failed", "buffer", buffer, "command", cmd.Name, "err", err)
+	w, e := c.ErrorWriter()
+	if e != nil {
+		c.logger.Error("unable to open error file", "err", e)
+		return
+	}
+	defer w.Close()
+	if _, e := io.WriteString(w, err.Error()); e != nil {
+		c.logger.Error("unable to write error file", "err", e)
+	}
+}
@@ -0,0 +1,68 @@
+package control
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/controller"
+)
+
+type errorCallback struct {
+	*testCallback
+}
+
+func (e *errorCallback) HandleError(buffer string, cmd *commander.Command, err error) error {
+	if buffer == "#quiet" {
+		return nil
+	}
+	return errors.New("custom " + cmd.Name)
+}
+
+func TestReportError(t *testing.T) {
+	cb := &testCallback{
+		started: make(chan controller.Controller),
+		handle:  errors.New("boom"),
+	}
+	ctl, server, msgs := testServer(t, cb)
+	var log bytes.Buffer
+	ctl.SetLogger(slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	go ctl.Listen()
+	<-cb.started
+
+	io.WriteString(server, "input #altid\n\thello\x00")
+	if msg := <-msgs; msg != "error\n#altid: input: boom" {
+		t.Errorf("incorrect error message %q", msg)
+	}
+	for _, want := range []string{
+		"level=DEBUG msg=command command=input buffer=#altid",
+		"level=ERROR msg=\"command failed\" buffer=#altid command=input",
+		"level=DEBUG msg=write kind=error bytes=",
+	} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("log does not contain %q, have %s", want, log.String())
+		}
+	}
+	io.WriteString(server, "shutdown\x00")
+}
+
+func TestErrorHandler(t *testing.T) {
+	cb := &testCallback{
+		started: make(chan controller.Controller),
+		handle:  errors.New("boom"),
+	}
+	ctl, server, msgs := testServer(t, cb)
+	ctl.SetCallbacks(&errorCallback{cb})
+	go ctl.Listen()
+	<-cb.started
+
+	io.WriteString(server, "input #quiet\n\thello\x00input #altid\n\thello\x00")
+	if msg := <-msgs; msg != "error\ncustom input" {
+		t.Errorf("incorrect error message %q", msg)
+	}
+	io.WriteString(server, "shutdown\x00")
+}
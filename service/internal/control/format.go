@@ -1,10 +1,25 @@
 package control
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/altid/libs/service/controller"
+	"github.com/altid/libs/service/internal/parse"
 )
 
+// Largest body sent in a single message, so that header and body fit in one 9P write at the default msize
+const maxBody = 8192 - 256
+
+// ErrNulByte is returned when a write to a text format contains our message delimiter
+var ErrNulByte = errors.New("message body contains a NUL byte")
+
 const (
 	errorFmt = iota
 	statusFmt
@@ -14,53 +29,220 @@ const (
 	feedFmt
 	mainFmt
 	imageFmt
-	//notifyFmt
 )
 
+var fmtNames = [...]string{"error", "status", "side", "nav", "title", "feed", "main", "image"}
+
 type prefix struct {
-	c *Control
-	fmt int
-	nfd *os.File
+	c    *Control
+	fmt  int
+	nfd  io.WriteCloser
 	args []string
+	// closed is guarded by c.l, as nfd may be shared with the ctl and outlive Close
+	closed bool
 }
 
 func newPrefix(c *Control, fmt int, args ...string) (*prefix, error) {
 	// Do some sanity checking here and return error if we ever need to
-	nfd, err := os.OpenFile(c.ctl.Name(), os.O_APPEND|os.O_WRONLY, 0644)
+	nfd, err := c.ctl.Writer()
 	if err != nil {
 		return nil, err
 	}
-	return &prefix{
-		nfd: nfd,
-		c: c,
-		fmt: fmt,
+	p := &prefix{
+		nfd:  nfd,
+		c:    c,
+		fmt:  fmt,
 		args: args,
-	}, nil
+	}
+	c.wl.Lock()
+	c.writers[p] = struct{}{}
+	c.wl.Unlock()
+	return p, nil
 }
 
+// Write sends b to the server as one or more messages
+// Text formats are split on line boundaries where possible, so no message body exceeds maxBody
+// Images are base64 encoded, see writeImage
 func (p *prefix) Write(b []byte) (int, error) {
 	p.c.l.Lock()
 	defer p.c.l.Unlock()
+	if p.closed {
+		return 0, os.ErrClosed
+	}
+	if e := p.track(b); e != nil {
+		return 0, e
+	}
 	switch p.fmt {
-	case errorFmt: 
-		return fmt.Fprintf(p.nfd, "error\n%s", b)
+	case errorFmt:
+		return writeBody(p.nfd, "error\n", b)
 	case statusFmt:
-		return fmt.Fprintf(p.nfd, "status %s\n\t%s", p.args[0], b)
+		return writeBody(p.nfd, fmt.Sprintf("status %s\n\t", p.args[0]), b)
+	case sideFmt:
+		return writeBody(p.nfd, fmt.Sprintf("side %s\n\t", p.args[0]), b)
 	case navFmt:
-		return fmt.Fprintf(p.nfd, "navi\n%s", b)
+		return writeBody(p.nfd, "navi\n", b)
 	case titleFmt:
-		return fmt.Fprintf(p.nfd, "title %s\n\t%s", p.args[0], b)
+		return writeBody(p.nfd, fmt.Sprintf("title %s\n\t", p.args[0]), b)
 	case feedFmt:
-		return fmt.Fprintf(p.nfd, "feed %s\n\t%s", p.args[0], b)
+		return writeBody(p.nfd, fmt.Sprintf("feed %s\n\t", p.args[0]), b)
+	case mainFmt:
+		return writeBody(p.nfd, fmt.Sprintf("main %s\n\t", p.args[0]), b)
 	case imageFmt:
-		return fmt.Fprintf(p.nfd, "image %s/%s\n\t%s", p.args[0], p.args[1], b)
+		return writeImage(p.nfd, fmt.Sprintf("image %s/%s\n\t", p.args[0], p.args[1]), b)
 	default:
 		return 0, fmt.Errorf("unknown format specifier supplied\n")
 	}
 }
 
+// track makes sure the buffer we write to exists, and updates its metadata
+func (p *prefix) track(b []byte) error {
+	if p.fmt == errorFmt {
+		p.c.logger.Debug("write", "kind", fmtNames[p.fmt], "bytes", len(b))
+		return nil
+	}
+	p.c.logger.Debug("write", "buffer", p.args[0], "kind", fmtNames[p.fmt], "bytes", len(b))
+	op := "write " + fmtNames[p.fmt]
+	if e := p.c.buffers.Write(op, p.args[0], p.fmt == mainFmt || p.fmt == feedFmt); e != nil {
+		return e
+	}
+	if p.fmt == titleFmt {
+		p.c.buffers.SetTitle(p.args[0], strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
 func (p *prefix) Close() error {
+	p.c.wl.Lock()
+	delete(p.c.writers, p)
+	p.c.wl.Unlock()
 	p.c.l.Lock()
 	defer p.c.l.Unlock()
+	if p.closed {
+		return os.ErrClosed
+	}
+	p.closed = true
 	return p.nfd.Close()
 }
+
+// writeBody writes header and body to w as delimited messages
+// A body larger than maxBody is split into several messages, each with the same header
+// The returned count is the number of bytes of body written
+func writeBody(w io.Writer, header string, body []byte) (int, error) {
+	if bytes.IndexByte(body, delim) >= 0 {
+		return 0, ErrNulByte
+	}
+
+	var n int
+	for len(body) > 0 {
+		chunk := body[:split(body, maxBody)]
+		if e := writeMsg(w, []byte(header), chunk); e != nil {
+			return n, e
+		}
+		n += len(chunk)
+		body = body[len(chunk):]
+	}
+
+	return n, nil
+}
+
+// Largest chunk of image data which fits in maxBody once encoded
+const maxImage = maxBody / 4 * 3
+
+// writeImage writes header and data to w as delimited messages, with data base64 encoded so it can hold our delimiter
+// Each message holds up to maxImage bytes of data, which the server decodes and appends to the image
+// The returned count is the number of bytes of data written
+func writeImage(w io.Writer, header string, data []byte) (int, error) {
+	var n int
+	for len(data) > 0 {
+		chunk := data[:min(len(data), maxImage)]
+		if e := writeMsg(w, []byte(header), []byte(base64.StdEncoding.EncodeToString(chunk))); e != nil {
+			return n, e
+		}
+		n += len(chunk)
+		data = data[len(chunk):]
+	}
+	return n, nil
+}
+
+// writeMsg writes a single delimited message to w
+func writeMsg(w io.Writer, parts ...[]byte) error {
+	msg := bytes.Join(parts, nil)
+	msg = append(msg, delim)
+	_, err := w.Write(msg)
+	return err
+}
+
+// split returns the length of the first chunk of b no larger than max
+// It prefers ending the chunk on a newline, and never splits a rune
+func split(b []byte, max int) int {
+	if len(b) <= max {
+		return len(b)
+	}
+	if i := bytes.LastIndexByte(b[:max], '\n'); i >= 0 {
+		return i + 1
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
+	}
+	if n == 0 {
+		return max
+	}
+	return n
+}
+
+// notifyMsg returns a notification as the single message sent to the server
+//
+//	notify <buffer>
+//		from <from>
+//		urgency <low|normal|critical>
+//		timeout <milliseconds>
+//		action <label>|<command>
+//		msg <msg>
+//
+// timeout is omitted when zero, and there is one action line per action
+// msg is always last, and may span multiple lines
+func notifyMsg(n *controller.Notification) ([]byte, error) {
+	if n.Buffer == "" {
+		return nil, errors.New("notification has no buffer")
+	}
+	if !n.Urgency.Valid() {
+		return nil, fmt.Errorf("unknown urgency %d", n.Urgency)
+	}
+	if n.Timeout < 0 {
+		return nil, errors.New("notification timeout cannot be negative")
+	}
+	for _, field := range []string{n.Buffer, n.From} {
+		if strings.ContainsAny(field, "\n\x00") {
+			return nil, fmt.Errorf("invalid notification field %q", field)
+		}
+	}
+	if strings.IndexByte(n.Msg, delim) >= 0 {
+		return nil, ErrNulByte
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "notify %s\n", n.Buffer)
+	fmt.Fprintf(&b, "\tfrom %s\n", n.From)
+	fmt.Fprintf(&b, "\turgency %s\n", n.Urgency)
+	if n.Timeout > 0 {
+		fmt.Fprintf(&b, "\ttimeout %d\n", n.Timeout.Milliseconds())
+	}
+	for _, a := range n.Actions {
+		if a.Label == "" || strings.ContainsAny(a.Label, "|\n\x00") || strings.ContainsAny(a.Command, "\n\x00") {
+			return nil, fmt.Errorf("invalid notification action %q", a.Label)
+		}
+		// Make sure a client activating this sends us something we can read back
+		if _, _, _, err := parse.ParseCmd(a.Command); err != nil {
+			return nil, fmt.Errorf("invalid command for action %q: %v", a.Label, err)
+		}
+		fmt.Fprintf(&b, "\taction %s|%s\n", a.Label, a.Command)
+	}
+	fmt.Fprintf(&b, "\tmsg %s", n.Msg)
+
+	if b.Len() > maxBody {
+		return nil, errors.New("notification too large")
+	}
+
+	return b.Bytes(), nil
+}
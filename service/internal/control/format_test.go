@@ -0,0 +1,159 @@
+package control
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/altid/libs/service/controller"
+)
+
+func TestWriteBody(t *testing.T) {
+	var b bytes.Buffer
+	n, err := writeBody(&b, "main #altid\n\t", []byte("hello\nworld\n"))
+	if err != nil || n != 12 {
+		t.Fatalf("have %d, %v", n, err)
+	}
+	if b.String() != "main #altid\n\thello\nworld\n\x00" {
+		t.Errorf("incorrect framing %q", b.String())
+	}
+
+	if _, err := writeBody(&b, "main #altid\n\t", []byte("hi\x00")); err != ErrNulByte {
+		t.Errorf("expected ErrNulByte, have %v", err)
+	}
+}
+
+func TestWriteBodyLarge(t *testing.T) {
+	line := strings.Repeat("x", 99) + "\n"
+	body := strings.Repeat(line, 300) + strings.Repeat("ü", maxBody)
+
+	var b bytes.Buffer
+	n, err := writeBody(&b, "side #altid\n\t", []byte(body))
+	if err != nil || n != len(body) {
+		t.Fatalf("have %d, %v", n, err)
+	}
+
+	var joined strings.Builder
+	msgs := strings.Split(strings.TrimSuffix(b.String(), "\x00"), "\x00")
+	for i, msg := range msgs {
+		if !strings.HasPrefix(msg, "side #altid\n\t") {
+			t.Fatalf("message %d missing header", i)
+		}
+		chunk := strings.TrimPrefix(msg, "side #altid\n\t")
+		if len(chunk) > maxBody {
+			t.Errorf("message %d too large: %d", i, len(chunk))
+		}
+		if !utf8.ValidString(chunk) {
+			t.Errorf("message %d split a rune", i)
+		}
+		joined.WriteString(chunk)
+	}
+	if len(msgs) < 2 || !strings.HasSuffix(msgs[0], "\n") {
+		t.Errorf("expected body split on a line boundary")
+	}
+	if joined.String() != body {
+		t.Error("body was not preserved across messages")
+	}
+}
+
+func TestWriteImage(t *testing.T) {
+	data := make([]byte, maxImage*2+10)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	var b bytes.Buffer
+	n, err := writeImage(&b, "image #altid/cat.png\n\t", data)
+	if err != nil || n != len(data) {
+		t.Fatalf("have %d, %v", n, err)
+	}
+
+	var joined []byte
+	msgs := strings.Split(strings.TrimSuffix(b.String(), "\x00"), "\x00")
+	if len(msgs) != 3 {
+		t.Fatalf("have %d messages, want 3", len(msgs))
+	}
+	for _, msg := range msgs {
+		body, ok := strings.CutPrefix(msg, "image #altid/cat.png\n\t")
+		if !ok || len(body) > maxBody {
+			t.Fatalf("bad message of %d bytes", len(msg))
+		}
+		chunk, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		joined = append(joined, chunk...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Error("image data not reassembled")
+	}
+}
+
+func TestNotifyMsg(t *testing.T) {
+	msg, err := notifyMsg(&controller.Notification{
+		Buffer:  "#altid",
+		From:    "halfwit",
+		Msg:     "ping\npong",
+		Urgency: controller.UrgencyCritical,
+		Timeout: 5 * time.Second,
+		Actions: []controller.Action{
+			{Label: "Reply", Command: "open #altid"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "notify #altid\n\tfrom halfwit\n\turgency critical\n\ttimeout 5000\n\taction Reply|open #altid\n\tmsg ping\npong"
+	if string(msg) != want {
+		t.Errorf("have %q, want %q", msg, want)
+	}
+
+	if !(controller.UrgencyLow < controller.UrgencyNormal && controller.UrgencyNormal < controller.UrgencyCritical) {
+		t.Error("urgencies do not order by severity")
+	}
+	if n := (controller.Notification{}); n.Urgency != controller.UrgencyNormal {
+		t.Error("zero urgency is not normal")
+	}
+
+	for _, bad := range []*controller.Notification{
+		{Msg: "no buffer"},
+		{Buffer: "#altid", Urgency: 12},
+		{Buffer: "#altid", Urgency: -2},
+		{Buffer: "#altid", Timeout: -time.Second},
+		{Buffer: "#altid\n", From: "halfwit"},
+		{Buffer: "#altid", Actions: []controller.Action{{Label: "a|b", Command: "open #altid"}}},
+		{Buffer: "#altid", Actions: []controller.Action{{Label: "Reply", Command: ""}}},
+	} {
+		if _, err := notifyMsg(bad); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
+
+// Writers on a stream transport share the ctl, so they must refuse writes once closed
+func TestWriterClosed(t *testing.T) {
+	ctl, _, _ := testServer(t, &testCallback{})
+	if e := ctl.CreateBuffer("#altid"); e != nil {
+		t.Fatal(e)
+	}
+	mw, err := ctl.MainWriter("#altid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, e := mw.Write([]byte("hello")); e != nil {
+		t.Fatal(e)
+	}
+	if e := mw.Close(); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := mw.Write([]byte("hello")); e != os.ErrClosed {
+		t.Errorf("expected os.ErrClosed writing after close, have %v", e)
+	}
+	if e := mw.Close(); e != os.ErrClosed {
+		t.Errorf("expected os.ErrClosed closing twice, have %v", e)
+	}
+}
@@ -0,0 +1,218 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/altid/libs/service/callback"
+	"github.com/altid/libs/service/commander"
+)
+
+// Finished jobs kept for listing
+const maxFinished = 32
+
+// ErrNoJob is returned when cancelling a job which is not running
+var ErrNoJob = errors.New("no such job")
+
+type jobState int
+
+const (
+	jobRunning jobState = iota
+	jobDone
+	jobFailed
+	jobCancelled
+)
+
+var jobStates = [...]string{"running", "done", "failed", "cancelled"}
+
+func (s jobState) String() string { return jobStates[s] }
+
+// job is a single run of an async command
+type job struct {
+	id     int
+	cmd    *commander.Command
+	cancel context.CancelFunc
+	state  jobState
+	msg    string
+	done   int
+	total  int
+}
+
+// status is the line written to the status file of the job's buffer
+func (j *job) status() string {
+	s := fmt.Sprintf("[%d] %s: ", j.id, j.cmd.Name)
+	if j.state != jobRunning {
+		return s + j.state.String()
+	}
+	s += j.msg
+	if j.total > 0 {
+		s += fmt.Sprintf(" (%d/%d)", j.done, j.total)
+	}
+	return s
+}
+
+// jobs tracks async commands, from when they start until long after they finish
+type jobs struct {
+	mu   sync.Mutex
+	next int
+	list map[int]*job
+}
+
+func newJobs() *jobs {
+	return &jobs{
+		list: make(map[int]*job),
+	}
+}
+
+func (js *jobs) add(cmd *commander.Command, cancel context.CancelFunc) *job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.next++
+	j := &job{
+		id:     js.next,
+		cmd:    cmd,
+		cancel: cancel,
+		msg:    "started",
+	}
+	js.list[j.id] = j
+	return j
+}
+
+// update applies fn to j, returning its new status line
+func (js *jobs) update(j *job, fn func(*job)) string {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	fn(j)
+	return j.status()
+}
+
+// finish records how j ended, dropping the oldest finished jobs over maxFinished
+func (js *jobs) finish(j *job, state jobState) string {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	j.state = state
+	var finished []int
+	for id, fj := range js.list {
+		if fj.state != jobRunning {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) > maxFinished {
+		sort.Ints(finished)
+		for _, id := range finished[:len(finished)-maxFinished] {
+			delete(js.list, id)
+		}
+	}
+	return j.status()
+}
+
+func (js *jobs) cancel(id int) error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	j, ok := js.list[id]
+	if !ok || j.state != jobRunning {
+		return fmt.Errorf("%w: %d", ErrNoJob, id)
+	}
+	j.cancel()
+	return nil
+}
+
+// result lists every job, oldest first
+func (js *jobs) result() *commander.Result {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	var ids []int
+	for id := range js.list {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	res := &commander.Result{Columns: []string{"id", "command", "buffer", "state", "progress"}}
+	for _, id := range ids {
+		j := js.list[id]
+		progress := j.msg
+		if j.total > 0 {
+			progress = fmt.Sprintf("%s (%d/%d)", j.msg, j.done, j.total)
+		}
+		res.AddRow(strconv.Itoa(id), j.cmd.Name, j.cmd.From, j.state.String(), progress)
+	}
+	return res
+}
+
+// progress is handed to a callback.AsyncRunner to report on its job
+type progress struct {
+	c *Control
+	j *job
+}
+
+func (p *progress) Update(msg string, done, total int) error {
+	return p.c.jobStatus(p.j, p.c.jobs.update(p.j, func(j *job) {
+		j.msg = msg
+		j.done = done
+		j.total = total
+	}))
+}
+
+// startJob runs cmd on its own goroutine, so it blocks neither other commands nor input to its buffer
+// Unlike handlers, jobs are not subject to the handler timeout
+func (c *Control) startJob(ctx context.Context, cmd *commander.Command) {
+	ctx = callback.WithBuffer(ctx, cmd.From)
+	if u := c.username(); u != "" {
+		ctx = callback.WithUsername(ctx, u)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	j := c.jobs.add(cmd, cancel)
+	c.logger.Debug("job started", "buffer", cmd.From, "command", cmd.Name, "job", j.id)
+	c.jobStatus(j, c.jobs.update(j, func(*job) {}))
+
+	go func() {
+		defer cancel()
+		res, err := c.cb.(callback.AsyncRunner).RunAsync(ctx, cmd, &progress{c, j})
+		switch {
+		case ctx.Err() == context.Canceled:
+			c.jobStatus(j, c.jobs.finish(j, jobCancelled))
+			return
+		case err != nil:
+			c.jobStatus(j, c.jobs.finish(j, jobFailed))
+			c.reportError(cmd, err)
+			return
+		}
+		c.jobStatus(j, c.jobs.finish(j, jobDone))
+		if res != nil {
+			if e := c.sendResult(cmd, res); e != nil {
+				c.reportError(cmd, e)
+			}
+		}
+	}()
+}
+
+// cancelJob handles `cancel <id>`
+func (c *Control) cancelJob(cmd *commander.Command) error {
+	if err := wantArgs(cmd, 1); err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid job id %q", cmd.Args[0])
+	}
+	return c.jobs.cancel(id)
+}
+
+// jobStatus writes the status line of j to the status file of its buffer
+// Jobs run from outside of a buffer have nowhere to show progress
+func (c *Control) jobStatus(j *job, status string) error {
+	if j.cmd.From == "" || !c.buffers.Has(j.cmd.From) {
+		return nil
+	}
+	w, err := c.StatusWriter(j.cmd.From)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	_, err = io.WriteString(w, status)
+	return err
+}
@@ -0,0 +1,63 @@
+package control
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/altid/libs/service/callback"
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/controller"
+)
+
+type asyncCallback struct {
+	*testCallback
+}
+
+func (a *asyncCallback) RunAsync(ctx context.Context, cmd *commander.Command, p callback.Progress) (*commander.Result, error) {
+	p.Update("50 messages", 50, 100)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestJobs(t *testing.T) {
+	cb := &asyncCallback{&testCallback{started: make(chan controller.Controller)}}
+	ctl, server, msgs := testServer(t, cb.testCallback)
+	ctl.SetCallbacks(cb)
+	ctl.SetCommands([]*commander.Command{{Name: "fetch", Heading: commander.ActionGroup, Async: true}})
+	if ctl.serviceCommand("cancel") == nil || ctl.serviceCommand("jobs") == nil {
+		t.Error("job commands not offered alongside async commands")
+	}
+	go ctl.Listen()
+	c := <-cb.started
+	c.CreateBuffer("#altid")
+	<-msgs
+
+	io.WriteString(server, "fetch #altid\n\thistory\x00")
+	for _, want := range []string{
+		"status #altid\n\t[1] fetch: started",
+		"status #altid\n\t[1] fetch: 50 messages (50/100)",
+	} {
+		if msg := <-msgs; msg != want {
+			t.Errorf("have %q, want %q", msg, want)
+		}
+	}
+
+	// The job must not hold up other commands
+	io.WriteString(server, "jobs #altid\n\tall\x00")
+	want := "result #altid\n\tcommand jobs\n\tcolumns id\tcommand\tbuffer\tstate\tprogress\n\trow 1\tfetch\t#altid\trunning\t50 messages (50/100)"
+	if msg := <-msgs; msg != want {
+		t.Errorf("have %q, want %q", msg, want)
+	}
+
+	io.WriteString(server, "cancel #altid\n\t1\x00")
+	if msg := <-msgs; msg != "status #altid\n\t[1] fetch: cancelled" {
+		t.Errorf("job not cancelled, have %q", msg)
+	}
+
+	io.WriteString(server, "cancel #altid\n\t1\x00")
+	if msg := <-msgs; msg != "error\n#altid: cancel: no such job: 1" {
+		t.Errorf("expected error cancelling a finished job, have %q", msg)
+	}
+	io.WriteString(server, "shutdown\x00")
+}
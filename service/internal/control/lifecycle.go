@@ -0,0 +1,127 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/altid/libs/service/callback"
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/controller"
+)
+
+var (
+	// ErrShutdown is returned from Listen after the service has been asked to shut down
+	ErrShutdown = errors.New("service shut down")
+	// ErrRestart is returned from Listen after the service has been asked to restart
+	// The caller re-executes the service once it has cleaned up
+	ErrRestart = errors.New("service restart")
+)
+
+// SetReload sets a function called on reload, before the callback's Reload
+func (c *Control) SetReload(fn func() error) {
+	c.reload = fn
+}
+
+// Signal runs the lifecycle command name, such as shutdown or reload, as though it had been sent on the ctl file
+// Failures are reported the same way as for commands from the ctl file
+func (c *Control) Signal(name string) {
+	c.logger.Info("signal", "command", name)
+	cmd := &commander.Command{Name: name}
+	if e := c.sendCommand(cmd); e != nil {
+		c.reportError(cmd, e)
+	}
+}
+
+// shutdown flushes all open writers, deletes our buffers and stops Listen
+func (c *Control) shutdown() {
+	c.logger.Info("shutdown")
+	c.flush()
+	c.deleteBuffers()
+	c.stop(ErrShutdown)
+}
+
+// restart is shutdown, but Listen returns ErrRestart
+// The new process starts with no buffers, so ours are deleted rather than left for it to create again
+func (c *Control) restart() {
+	c.logger.Info("restart")
+	c.flush()
+	c.deleteBuffers()
+	c.stop(ErrRestart)
+}
+
+func (c *Control) deleteBuffers() {
+	c.buffers.Range(func(b controller.Buffer) bool {
+		c.DeleteBuffer(b.Name)
+		return true
+	})
+}
+
+func (c *Control) doReload() error {
+	c.logger.Info("reload")
+	if c.reload != nil {
+		if e := c.reload(); e != nil {
+			return fmt.Errorf("reload: %w", e)
+		}
+	}
+	if r, ok := c.cb.(callback.Reloader); ok {
+		return r.Reload()
+	}
+	return nil
+}
+
+// stop records why Listen should return and cancels its context
+// It does nothing to a Control which was never connected
+func (c *Control) stop(exit error) {
+	c.el.Lock()
+	if c.exit == nil {
+		c.exit = exit
+	}
+	cancel := c.cancel
+	c.el.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// exitErr returns the error Listen should return once ctx is done
+func (c *Control) exitErr(ctx context.Context) error {
+	c.el.Lock()
+	exit := c.exit
+	c.el.Unlock()
+
+	switch exit {
+	case ErrShutdown:
+		return ErrShutdown
+	case ErrRestart:
+		return ErrRestart
+	default:
+		return ctx.Err()
+	}
+}
+
+// flush closes every writer still open
+func (c *Control) flush() {
+	c.wl.Lock()
+	open := make([]*prefix, 0, len(c.writers))
+	for p := range c.writers {
+		open = append(open, p)
+	}
+	c.wl.Unlock()
+	for _, p := range open {
+		p.Close()
+	}
+}
+
+// dump logs the state of the service, for inspecting a running instance
+func (c *Control) dump() {
+	c.wl.Lock()
+	writers := len(c.writers)
+	c.wl.Unlock()
+
+	list := c.buffers.List()
+	c.logger.Info("state", "buffers", len(list), "writers", writers, "queued", c.pool.queued(), "username", c.username())
+	for _, b := range list {
+		c.logger.Info("buffer", "buffer", b.Name, "title", b.Title, "unread", b.Unread, "activity", b.Activity)
+	}
+}
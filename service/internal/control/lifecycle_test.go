@@ -0,0 +1,135 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/altid/libs/markup"
+	"github.com/altid/libs/service/controller"
+	"github.com/altid/libs/service/transport"
+)
+
+type testCallback struct {
+	started chan controller.Controller
+	reloads int
+	handle  error
+}
+
+func (t *testCallback) Connect(string) error               { return nil }
+func (t *testCallback) Handle(string, *markup.Lexer) error { return t.handle }
+func (t *testCallback) Reload() error                      { t.reloads++; return nil }
+func (t *testCallback) Start(c controller.Controller) error {
+	t.started <- c
+	select {}
+}
+
+// testServer runs the given Control against an in-memory server, returning a channel of every message it receives
+func testServer(t *testing.T, cb *testCallback) (*Control, io.Writer, chan string) {
+	t.Helper()
+	tr, server := transport.Pipe()
+	msgs := make(chan string, 64)
+	go func() {
+		defer close(msgs)
+		rd := bufio.NewReader(server)
+		// Registration
+		rd.ReadString('\n')
+		for {
+			msg, err := rd.ReadString('\x00')
+			if err != nil {
+				return
+			}
+			msgs <- strings.TrimSuffix(msg, "\x00")
+		}
+	}()
+
+	ctl, err := ConnectService(context.Background(), "zzyzx", tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctl.SetCallbacks(cb)
+	return ctl, server, msgs
+}
+
+func TestShutdown(t *testing.T) {
+	cb := &testCallback{started: make(chan controller.Controller)}
+	ctl, server, msgs := testServer(t, cb)
+
+	var reloads int
+	ctl.SetReload(func() error {
+		reloads++
+		return nil
+	})
+
+	errs := make(chan error)
+	go func() { errs <- ctl.Listen() }()
+
+	c := <-cb.started
+	c.CreateBuffer("#altid")
+	mw, _ := c.MainWriter("#altid")
+
+	io.WriteString(server, "reload\x00shutdown\x00")
+	if e := <-errs; e != ErrShutdown {
+		t.Errorf("expected ErrShutdown, have %v", e)
+	}
+
+	if reloads != 1 || cb.reloads != 1 {
+		t.Errorf("reload not run, have %d and %d", reloads, cb.reloads)
+	}
+	if c.HasBuffer("#altid") {
+		t.Error("buffer not deleted on shutdown")
+	}
+	if _, err := mw.Write([]byte("hello")); err == nil {
+		t.Error("writer still usable after shutdown")
+	}
+
+	var all bytes.Buffer
+	for msg := range msgs {
+		all.WriteString(msg + "\n")
+	}
+	if !strings.Contains(all.String(), "delete #altid") {
+		t.Errorf("server not told to delete buffer, have %q", all.String())
+	}
+}
+
+// A signal may arrive before Listen has started
+func TestSignalBeforeListen(t *testing.T) {
+	(&Control{}).stop(ErrShutdown)
+
+	cb := &testCallback{started: make(chan controller.Controller, 1)}
+	ctl, _, _ := testServer(t, cb)
+	ctl.Signal("shutdown")
+	if e := ctl.Listen(); e != ErrShutdown {
+		t.Errorf("expected ErrShutdown, have %v", e)
+	}
+}
+
+// The new process starts afresh, so restart deletes our buffers as shutdown does
+func TestRestart(t *testing.T) {
+	cb := &testCallback{started: make(chan controller.Controller)}
+	ctl, server, msgs := testServer(t, cb)
+
+	errs := make(chan error)
+	go func() { errs <- ctl.Listen() }()
+
+	c := <-cb.started
+	c.CreateBuffer("#altid")
+	io.WriteString(server, "restart\x00")
+	if e := <-errs; e != ErrRestart {
+		t.Errorf("expected ErrRestart, have %v", e)
+	}
+	if c.HasBuffer("#altid") {
+		t.Error("buffer not deleted on restart")
+	}
+
+	var all bytes.Buffer
+	for msg := range msgs {
+		all.WriteString(msg + "\n")
+	}
+	if !strings.Contains(all.String(), "delete #altid") {
+		t.Errorf("server not told to delete buffer, have %q", all.String())
+	}
+}
@@ -0,0 +1,110 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const (
+	defaultWorkers = 16
+	defaultQueue   = 128
+)
+
+// ErrBusy is reported when a buffer has too much input waiting to be handled, and further input is dropped
+var ErrBusy = errors.New("buffer busy, input dropped")
+
+// pool runs handlers on per-buffer workers
+// Work for a single buffer runs in the order it was submitted, while different buffers run in parallel
+// At most workers handlers run at once, and at most depth are waiting per buffer
+type pool struct {
+	mu     sync.Mutex
+	queues map[string]*queue
+	sem    chan struct{}
+	depth  int
+}
+
+type queue struct {
+	pending []func()
+}
+
+func newPool(workers, depth int) *pool {
+	if workers < 1 {
+		workers = defaultWorkers
+	}
+	if depth < 1 {
+		depth = defaultQueue
+	}
+	return &pool{
+		queues: make(map[string]*queue),
+		sem:    make(chan struct{}, workers),
+		depth:  depth,
+	}
+}
+
+// submit queues fn to run on the worker for key, starting one if needed
+// Workers exit once their queue is empty, so idle buffers cost nothing
+func (p *pool) submit(ctx context.Context, key string, fn func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	q, ok := p.queues[key]
+	if !ok {
+		q = &queue{}
+		p.queues[key] = q
+		go p.run(ctx, key, q)
+	}
+	if len(q.pending) >= p.depth {
+		return ErrBusy
+	}
+	q.pending = append(q.pending, fn)
+	return nil
+}
+
+func (p *pool) run(ctx context.Context, key string, q *queue) {
+	for {
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			p.drop(key)
+			return
+		}
+		// We may have won the race against cancellation
+		if ctx.Err() != nil {
+			<-p.sem
+			p.drop(key)
+			return
+		}
+
+		p.mu.Lock()
+		if len(q.pending) == 0 {
+			delete(p.queues, key)
+			p.mu.Unlock()
+			<-p.sem
+			return
+		}
+		fn := q.pending[0]
+		q.pending = q.pending[1:]
+		p.mu.Unlock()
+
+		fn()
+		<-p.sem
+	}
+}
+
+// drop discards anything still waiting for key once we've been cancelled
+func (p *pool) drop(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.queues, key)
+}
+
+// queued returns how much work is waiting across all buffers
+func (p *pool) queued() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var n int
+	for _, q := range p.queues {
+		n += len(q.pending)
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+package control
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := newPool(4, 0)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[string][]int)
+	for i := 0; i < 100; i++ {
+		for _, buf := range []string{"#a", "#b", "#c"} {
+			i, buf := i, buf
+			wg.Add(1)
+			if e := p.submit(ctx, buf, func() {
+				defer wg.Done()
+				mu.Lock()
+				seen[buf] = append(seen[buf], i)
+				mu.Unlock()
+			}); e != nil {
+				t.Fatal(e)
+			}
+		}
+	}
+	wg.Wait()
+
+	for buf, order := range seen {
+		for i, n := range order {
+			if i != n {
+				t.Fatalf("%s handled out of order: %v", buf, order)
+			}
+		}
+	}
+}
+
+func TestPoolParallel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := newPool(2, 1)
+	stall := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	p.submit(ctx, "#slow", func() {
+		close(started)
+		<-stall
+	})
+	<-started
+
+	// #fast shouldn't wait on #slow
+	p.submit(ctx, "#fast", func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("one stalled buffer blocked another")
+	}
+
+	// #slow is running, so one more fits in its queue
+	if e := p.submit(ctx, "#slow", func() {}); e != nil {
+		t.Error(e)
+	}
+	if e := p.submit(ctx, "#slow", func() {}); e != ErrBusy {
+		t.Errorf("expected ErrBusy, have %v", e)
+	}
+	close(stall)
+}
+
+func TestPoolCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := newPool(1, 0)
+	stall := make(chan struct{})
+	p.submit(ctx, "#a", func() { <-stall })
+
+	ran := make(chan struct{}, 1)
+	p.submit(ctx, "#b", func() { ran <- struct{}{} })
+	cancel()
+	close(stall)
+
+	select {
+	case <-ran:
+		t.Error("queued input ran after cancellation")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,132 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/internal/parse"
+)
+
+// Messages on the ctl are terminated with a NUL byte
+const delim = '\x00'
+
+// Upper bound on a single incoming message, anything larger is reported as an error
+const maxMessage = 1 << 20
+
+// MessageError is reported when a message read from the ctl cannot be parsed into a command
+// It does not stop the Control from reading further messages
+type MessageError struct {
+	Msg []byte
+	Err error
+}
+
+func (e *MessageError) Error() string {
+	return fmt.Sprintf("malformed message %q: %v", e.Msg, e.Err)
+}
+
+func (e *MessageError) Unwrap() error { return e.Err }
+
+// ErrClosed is returned when the server closes our ctl
+var ErrClosed = errors.New("ctl closed by server")
+
+// readCommands reads delimited messages from the ctl until ctx is cancelled or the ctl is closed
+// Each message is parsed and sent on c.cmds, which is closed when reading stops
+func (c *Control) readCommands(ctx context.Context) {
+	defer close(c.cmds)
+
+	// Close the ctl to unblock any pending read
+	go func() {
+		<-ctx.Done()
+		c.ctl.Close()
+	}()
+
+	sc := bufio.NewScanner(c.ctl)
+	sc.Buffer(make([]byte, 0, 1024), maxMessage)
+	sc.Split(splitMessage)
+	for sc.Scan() {
+		msg := sc.Bytes()
+		if len(bytes.TrimSpace(msg)) == 0 {
+			continue
+		}
+		cmd, err := c.fromBytes(msg)
+		if err != nil {
+			// The scanner reuses its buffer, so hand off a copy
+			err = &MessageError{
+				Msg: append([]byte(nil), msg...),
+				Err: err,
+			}
+			if !c.report(ctx, err) {
+				return
+			}
+			continue
+		}
+		select {
+		case c.cmds <- cmd:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	// Cancellation closes the ctl underneath us, which isn't an error
+	if ctx.Err() != nil {
+		return
+	}
+
+	err := sc.Err()
+	if err == nil {
+		err = ErrClosed
+	}
+	c.report(ctx, err)
+}
+
+// fromBytes parses msg into a command
+// The service's own commands ending in a RestParam are split no further, so the rest of the line arrives as written
+func (c *Control) fromBytes(msg []byte) (*commander.Command, error) {
+	name, _, _, err := parse.ParseCmdRaw(string(msg))
+	if err != nil {
+		return nil, err
+	}
+	sc := c.serviceCommand(name)
+	if sc == nil || sc.MaxArgs() < 0 {
+		return c.commander.FromBytes(msg)
+	}
+	_, from, args, err := parse.ParseCmdN(string(msg), sc.MaxArgs())
+	if err != nil {
+		return nil, err
+	}
+	return &commander.Command{
+		Name:    name,
+		From:    from,
+		Args:    args,
+		Heading: commander.DefaultGroup,
+	}, nil
+}
+
+// report sends err to Listen, returning false if we've been cancelled
+func (c *Control) report(ctx context.Context, err error) bool {
+	select {
+	case c.errs <- err:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// splitMessage is a bufio.SplitFunc which splits on our delimiter
+// A trailing message without a delimiter is returned when the ctl is closed
+func splitMessage(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, delim); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
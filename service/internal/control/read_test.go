@@ -0,0 +1,107 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/internal/command"
+	"github.com/altid/libs/service/transport"
+)
+
+func newTestControl(t *testing.T, ctx context.Context) (*Control, io.ReadWriteCloser) {
+	t.Helper()
+	tr, server := transport.Pipe()
+
+	// Eat the registration line
+	go bufio.NewReader(server).ReadString('\n')
+	ctl, err := ConnectService(ctx, "zzyzx", tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctl.commander = &command.Command{
+		SendCommand:     ctl.sendCommand,
+		CtrlDataCommand: ctl.ctrlData,
+	}
+
+	return ctl, server
+}
+
+func TestReadCommands(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctl, server := newTestControl(t, ctx)
+	defer server.Close()
+	go ctl.readCommands(ctx)
+
+	long := strings.Repeat("a", 4096)
+	go func() {
+		io.WriteString(server, "open #altid\x00input #altid\n\thel")
+		io.WriteString(server, "lo world\x00\x00 \x00")
+		io.WriteString(server, "input #altid\n\t"+long+"\x00")
+	}()
+
+	for _, want := range []*commander.Command{
+		{Name: "open", Args: []string{"#altid"}},
+		{Name: "input", From: "#altid", Args: []string{"hello", "world"}},
+		{Name: "input", From: "#altid", Args: []string{long}},
+	} {
+		var cmd *commander.Command
+		select {
+		case cmd = <-ctl.cmds:
+		case e := <-ctl.errs:
+			t.Fatal(e)
+		}
+		if cmd.Name != want.Name || cmd.From != want.From || strings.Join(cmd.Args, " ") != strings.Join(want.Args, " ") {
+			t.Errorf("have %+v, want %+v", cmd, want)
+		}
+	}
+}
+
+func TestReadCommandsErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctl, server := newTestControl(t, ctx)
+	go ctl.readCommands(ctx)
+	go io.WriteString(server, "\n\tno name\x00quit\x00")
+
+	var me *MessageError
+	if e := <-ctl.errs; !errors.As(e, &me) {
+		t.Errorf("expected a MessageError, have %v", e)
+	}
+	if cmd := <-ctl.cmds; cmd.Name != "quit" {
+		t.Errorf("unable to read past a malformed message, have %+v", cmd)
+	}
+
+	server.Close()
+	if e := <-ctl.errs; e != ErrClosed {
+		t.Errorf("expected ErrClosed, have %v", e)
+	}
+}
+
+func TestReadCommandsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ctl, server := newTestControl(t, ctx)
+	defer server.Close()
+	go ctl.readCommands(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-ctl.cmds:
+		if ok {
+			t.Error("received a command after cancellation")
+		}
+	case e := <-ctl.errs:
+		t.Errorf("received an error after cancellation: %v", e)
+	case <-time.After(time.Second):
+		t.Error("reader did not stop on cancellation")
+	}
+}
@@ -0,0 +1,154 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/altid/libs/service/callback"
+	"github.com/altid/libs/service/commander"
+)
+
+// serviceCommand returns the command from the service's list with the given name or alias, or nil if there is none
+func (c *Control) serviceCommand(name string) *commander.Command {
+	for _, cmd := range c.cmdlist {
+		if cmd.Name == name {
+			return cmd
+		}
+		for _, alias := range cmd.Alias {
+			if alias == name {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+// runs reports whether the service runs its own commands
+func (c *Control) runs() bool {
+	switch c.cb.(type) {
+	case callback.ContextRunner, callback.Runner:
+		return true
+	}
+	return false
+}
+
+// async reports whether the service runs async commands
+func (c *Control) async() bool {
+	_, ok := c.cb.(callback.AsyncRunner)
+	return ok
+}
+
+// run passes cmd to the service, preferring callback.ContextRunner, and sends any Result back to the buffer it came from
+func (c *Control) run(ctx context.Context, cmd *commander.Command) error {
+	var res *commander.Result
+	var err error
+	if r, ok := c.cb.(callback.ContextRunner); ok {
+		ctx, cancel := c.requestContext(ctx, cmd.From)
+		defer cancel()
+		res, err = r.RunContext(ctx, cmd)
+	} else {
+		res, err = c.cb.(callback.Runner).Run(cmd)
+	}
+	if err != nil || res == nil {
+		return err
+	}
+	return c.sendResult(cmd, res)
+}
+
+// sendResult writes res to the buffer cmd came from
+func (c *Control) sendResult(cmd *commander.Command, res *commander.Result) error {
+	msgs, err := resultMsgs(cmd, res)
+	if err != nil {
+		return err
+	}
+	c.logger.Debug("result", "buffer", cmd.From, "command", cmd.Name, "rows", len(res.Rows), "bytes", len(res.Text))
+	c.l.Lock()
+	defer c.l.Unlock()
+	for _, msg := range msgs {
+		if e := writeMsg(c.ctl, msg); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// resultMsgs encodes a Result as one or more messages, each no larger than maxBody
+//
+//	result <buffer>
+//		command <name>
+//		columns <cell>\t<cell>
+//		row <cell>\t<cell>
+//		text <markup>
+//
+// Columns are optional, and repeated in each message along with the buffer and command
+// Rows come first, and text last; either may be split across messages
+func resultMsgs(cmd *commander.Command, r *commander.Result) ([][]byte, error) {
+	if strings.ContainsAny(cmd.From, "\n\x00") {
+		return nil, fmt.Errorf("invalid result buffer %q", cmd.From)
+	}
+	header := "result"
+	if cmd.From != "" {
+		header += " " + cmd.From
+	}
+	header += "\n\tcommand " + cmd.Name
+	if len(r.Columns) > 0 {
+		cols, err := resultCells(r.Columns)
+		if err != nil {
+			return nil, err
+		}
+		header += "\n\tcolumns " + cols
+	}
+	// Leave room for the body in every message
+	if len(header) > maxBody/2 {
+		return nil, errors.New("result header too large")
+	}
+
+	var msgs [][]byte
+	var rows strings.Builder
+	for _, row := range r.Rows {
+		cells, err := resultCells(row)
+		if err != nil {
+			return nil, err
+		}
+		line := "\n\trow " + cells
+		if len(header)+len(line) > maxBody {
+			return nil, errors.New("result row too large")
+		}
+		if len(header)+rows.Len()+len(line) > maxBody {
+			msgs = append(msgs, []byte(header+rows.String()))
+			rows.Reset()
+		}
+		rows.WriteString(line)
+	}
+	if rows.Len() > 0 {
+		msgs = append(msgs, []byte(header+rows.String()))
+	}
+
+	if strings.IndexByte(r.Text, delim) >= 0 {
+		return nil, ErrNulByte
+	}
+	text := []byte(r.Text)
+	prefix := header + "\n\ttext "
+	for len(text) > 0 {
+		chunk := text[:split(text, maxBody-len(prefix))]
+		msgs = append(msgs, append([]byte(prefix), chunk...))
+		text = text[len(chunk):]
+	}
+
+	// An empty result still tells the client the command finished
+	if len(msgs) == 0 {
+		msgs = append(msgs, []byte(header))
+	}
+	return msgs, nil
+}
+
+func resultCells(cells []string) (string, error) {
+	for _, cell := range cells {
+		if strings.ContainsAny(cell, "\t\n\x00") {
+			return "", fmt.Errorf("invalid result cell %q", cell)
+		}
+	}
+	return strings.Join(cells, "\t"), nil
+}
@@ -0,0 +1,99 @@
+package control
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/controller"
+)
+
+type runCallback struct {
+	*testCallback
+}
+
+func (r *runCallback) Run(cmd *commander.Command) (*commander.Result, error) {
+	switch cmd.Name {
+	case "list":
+		res := &commander.Result{Columns: []string{"channel", "users"}}
+		res.AddRow("#altid", "42")
+		res.AddRow("#go-nuts", "7")
+		return res, nil
+	case "whois":
+		return &commander.Result{Text: "**" + strings.Join(cmd.Args, " ") + "** is away"}, nil
+	case "echo":
+		return &commander.Result{Text: strings.Join(cmd.Args, "|")}, nil
+	}
+	return nil, errors.New("no such command")
+}
+
+func TestRunResult(t *testing.T) {
+	cb := &runCallback{&testCallback{started: make(chan controller.Controller)}}
+	ctl, server, msgs := testServer(t, cb.testCallback)
+	ctl.SetCallbacks(cb)
+	ctl.SetCommands([]*commander.Command{
+		{Name: "list", Heading: commander.ActionGroup},
+		{Name: "whois", Alias: []string{"wi"}, Heading: commander.ActionGroup},
+		{Name: "echo", Heading: commander.ActionGroup, Params: []commander.Param{{Name: "to"}, {Name: "text", Type: commander.RestParam}}},
+	})
+	go ctl.Listen()
+	<-cb.started
+
+	io.WriteString(server, "list #altid\n\t#*\x00wi #altid\n\thalfwit\x00echo #altid\n\t\"half wit\" it's  \"raw\"\x00")
+	for _, want := range []string{
+		"result #altid\n\tcommand list\n\tcolumns channel\tusers\n\trow #altid\t42\n\trow #go-nuts\t7",
+		"result #altid\n\tcommand whois\n\ttext **halfwit** is away",
+		"result #altid\n\tcommand echo\n\ttext half wit|it's  \"raw\"",
+	} {
+		if msg := <-msgs; msg != want {
+			t.Errorf("have %q, want %q", msg, want)
+		}
+	}
+	io.WriteString(server, "shutdown\x00")
+}
+
+func TestResultMsgs(t *testing.T) {
+	cmd := &commander.Command{Name: "list", From: "#altid"}
+
+	res := &commander.Result{}
+	for i := 0; i < 1000; i++ {
+		res.AddRow("#channel", strings.Repeat("x", 20))
+	}
+	res.Text = strings.Repeat("line\n", 2000)
+	msgs, err := resultMsgs(cmd, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows, text int
+	for _, msg := range msgs {
+		if len(msg) > maxBody {
+			t.Errorf("message of %d bytes is over the limit", len(msg))
+		}
+		if !strings.HasPrefix(string(msg), "result #altid\n\tcommand list\n\t") {
+			t.Errorf("message missing its header: %.40q", msg)
+		}
+		rows += strings.Count(string(msg), "\n\trow ")
+		if i := strings.Index(string(msg), "\n\ttext "); i >= 0 {
+			text += len(msg) - i - len("\n\ttext ")
+		}
+	}
+	if rows != 1000 || text != len(res.Text) {
+		t.Errorf("result not preserved across messages, have %d rows and %d bytes of text", rows, text)
+	}
+
+	if msgs, _ := resultMsgs(cmd, &commander.Result{}); len(msgs) != 1 || string(msgs[0]) != "result #altid\n\tcommand list" {
+		t.Errorf("empty result not sent, have %q", msgs)
+	}
+
+	for _, bad := range []*commander.Result{
+		{Rows: [][]string{{"tab\tbed"}}},
+		{Columns: []string{"new\nline"}},
+		{Text: "nul\x00"},
+	} {
+		if _, err := resultMsgs(cmd, bad); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
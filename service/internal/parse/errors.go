@@ -0,0 +1,72 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError describes where and why a ctl file failed to parse
+type ParseError struct {
+	// Line and Column are where the error was found, counting from 1; Column counts runes
+	Line   int
+	Column int
+	// Snippet is the line holding the error
+	Snippet string
+	// Msg describes what was found, and Expected what should have been
+	Msg      string
+	Expected string
+	// Err is the underlying error, if any, such as a *QuoteError
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	s := fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+	if e.Expected != "" {
+		s += ", expected " + e.Expected
+	}
+	return s
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// newParseError returns a ParseError for the given byte offset in src
+func newParseError(src []byte, offset int, msg, expected string) *ParseError {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	start := bytes.LastIndexByte(src[:offset], '\n') + 1
+	end := bytes.IndexByte(src[offset:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += offset
+	}
+	return &ParseError{
+		Line:     bytes.Count(src[:start], []byte{'\n'}) + 1,
+		Column:   utf8.RuneCount(src[start:offset]) + 1,
+		Snippet:  string(src[start:end]),
+		Msg:      msg,
+		Expected: expected,
+	}
+}
+
+// ParseErrors is every error found by ParseCtlFileLenient, in the order they were found
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, pe := range e {
+		errs[i] = pe
+	}
+	return errs
+}
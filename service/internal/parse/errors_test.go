@@ -0,0 +1,94 @@
+package parse
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/altid/libs/service/commander"
+)
+
+func TestParseError(t *testing.T) {
+	for _, tc := range []struct {
+		src      string
+		line     int
+		column   int
+		snippet  string
+		expected string
+	}{
+		{"general:\n\topen\n\tclose|\n", 3, 8, "\tclose|", "a command name"},
+		{"general:\n\topen\nemotes\n\tme\n", 3, 7, "emotes", "':' ending the heading"},
+		{"general: extra\n", 1, 9, "general: extra", "a newline"},
+		{"general:\n\tkick\t<nick> \"<reason>\t# Kick\n", 2, 14, "\tkick\t<nick> \"<reason>\t# Kick", "a closing \""},
+		{"general:\n\tsay\tsomething\\", 2, 15, "\tsay\tsomething\\", "a character after it"},
+		{"\n\topen\n", 2, 2, "\topen", "a heading"},
+		{"general:\n\tok\nbad name:\n", 3, 1, "bad name:", "a heading name"},
+		{"general:\n\tdéjà\t\"vu\n", 2, 7, "\tdéjà\t\"vu", "a closing \""},
+		{"general", 1, 8, "general", "':' ending the heading"},
+	} {
+		_, err := ParseCtlFile([]byte(tc.src))
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%q: expected ParseError, have %v", tc.src, err)
+			continue
+		}
+		if pe.Line != tc.line || pe.Column != tc.column || pe.Snippet != tc.snippet || pe.Expected != tc.expected {
+			t.Errorf("%q: have line %d column %d snippet %q expected %q", tc.src, pe.Line, pe.Column, pe.Snippet, pe.Expected)
+		}
+	}
+
+	_, err := ParseCtlFile([]byte("general:\n\tsay\t\"hi\n"))
+	var qe *QuoteError
+	if !errors.As(err, &qe) {
+		t.Errorf("expected the QuoteError to be kept, have %v", err)
+	}
+	if want := "line 2, column 6: unterminated \" quote, expected a closing \""; err.Error() != want {
+		t.Errorf("have %q, want %q", err, want)
+	}
+}
+
+func TestParseCtlFileLenient(t *testing.T) {
+	src := []byte(`general:
+	open	<buffer>	# Open a buffer
+	close|	<buffer>
+	say	"unterminated
+	quit	# Exit
+broken heading
+	lost	# Dropped with its heading
+emotes:
+	me	<action>
+`)
+	cmds, err := ParseCtlFileLenient(src)
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ParseErrors, have %v", err)
+	}
+	var lines []int
+	for _, pe := range errs {
+		lines = append(lines, pe.Line)
+	}
+	if len(lines) != 3 || lines[0] != 3 || lines[1] != 4 || lines[2] != 6 {
+		t.Errorf("have errors on lines %v, want 3, 4 and 6: %v", lines, err)
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 3 {
+		t.Errorf("expected the first ParseError, have %v", pe)
+	}
+
+	var names []string
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name)
+	}
+	if len(names) != 3 || names[0] != "open" || names[1] != "quit" || names[2] != "me" {
+		t.Errorf("have commands %q, want open, quit and me", names)
+	}
+	if cmds[2].Heading != commander.ActionGroup {
+		t.Errorf("me has heading %s", cmds[2].Heading)
+	}
+
+	if _, err := ParseCtlFile(src); err == nil {
+		t.Error("expected an error from the strict parser")
+	}
+	if _, err := ParseCtlFileLenient([]byte("general:\n\topen\n")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -2,7 +2,6 @@ package parse
 
 import (
 	"strings"
-	"unicode/utf8"
 
 	"github.com/altid/libs/service/commander"
 )
@@ -18,11 +17,17 @@ type lexer struct {
 	items   chan item
 	state   stateFn
 	heading commander.ComGroup
+	// headingName is the heading when it's UnknownGroup
+	headingName string
+	// err is set along with each parserError item
+	err *ParseError
 }
 
 type item struct {
 	itemType byte
 	data     []byte
+	// pos is the offset of data in src
+	pos int
 }
 
 func (l *lexer) next() item {
@@ -36,21 +41,23 @@ func (l *lexer) next() item {
 	}
 }
 
+// nextChar reads a single byte
+// Every byte of a multi-byte rune is outside of ASCII, so none can be mistaken for the delimiters we look for
 func (l *lexer) nextChar() byte {
 	if l.pos >= len(l.src) {
 		l.width = 0
 		return parserEOF
 	}
-	rune, width := utf8.DecodeRune(l.src[l.pos:])
-	l.width = width
-	l.pos += l.width
-	return byte(rune)
+	l.width = 1
+	l.pos++
+	return l.src[l.pos-1]
 }
 
 func (l *lexer) emit(t byte) {
 	l.items <- item{
 		t,
 		l.src[l.start:l.pos],
+		l.start,
 	}
 	l.start = l.pos
 }
@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -13,9 +14,42 @@ const (
 	cmdErr
 )
 
+// ParseCmd reads a command written to the ctl file, splitting its arguments with Split
+// The Offset of any QuoteError is within cmd
 func ParseCmd(cmd string) (string, string, []string, error) {
-	var name, from string
-	var args []string
+	return ParseCmdN(cmd, -1)
+}
+
+// ParseCmdN is ParseCmd, splitting the arguments with SplitN
+func ParseCmdN(cmd string, n int) (string, string, []string, error) {
+	name, from, raw, err := ParseCmdRaw(cmd)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if BareArg(cmd, from, raw) {
+		raw, from = from, ""
+	}
+	args, err := SplitN(raw, n)
+	if err != nil {
+		// Place a QuoteError within cmd, rather than the args
+		var qe *QuoteError
+		if errors.As(err, &qe) {
+			qe.Offset += strings.LastIndex(cmd, raw)
+		}
+		return "", "", nil, err
+	}
+	return name, from, args, nil
+}
+
+// BareArg reports whether cmd, read by ParseCmdRaw into from and args, has no args section
+// The buffer is then really the argument, as in "open #altid" or "quit bye"
+// A command written with String always has an args section when From is set, even with no Args
+func BareArg(cmd, from, args string) bool {
+	return from != "" && strings.TrimSpace(args) == "" && !strings.Contains(cmd, "\n\t")
+}
+
+// ParseCmdRaw is ParseCmd, returning the arguments as written instead of split
+func ParseCmdRaw(cmd string) (name, from, args string, err error) {
 	l := &lexer{
 		src:   []byte(cmd),
 		items: make(chan item, 2),
@@ -25,18 +59,16 @@ func ParseCmd(cmd string) (string, string, []string, error) {
 		i := l.next()
 		switch i.itemType {
 		case cmdErr:
-			return "", "", nil, fmt.Errorf("%s", i.data)
+			return "", "", "", fmt.Errorf("%s", i.data)
 		case cmdName:
 			name = string(i.data)
 		case cmdFrom:
 			from = string(i.data)
 		case cmdArgs:
-			args = strings.Fields(string(i.data))
+			args = strings.TrimPrefix(string(i.data), "\t")
 		case parserEOF:
-			// Clean up, possible on /quit, etc
-			if from != "" && len(args) == 0 {
-				args = strings.Fields(from)
-				from = ""
+			if name == "" || strings.ContainsAny(name, " \t\n") {
+				return "", "", "", errors.New("no command name found")
 			}
 			return name, from, args, nil
 		}
@@ -73,6 +105,9 @@ func parseCmdFrom(l *lexer) stateFn {
 		}
 		switch l.nextChar() {
 		case parserEOF:
+			if l.pos > l.start {
+				l.emit(cmdFrom)
+			}
 			l.emit(parserEOF)
 			return nil
 		case '\n':
@@ -88,6 +123,7 @@ func parseCmdArgs(l *lexer) stateFn {
 	for {
 		if l.nextChar() == parserEOF {
 			l.emit(cmdArgs)
+			l.emit(parserEOF)
 			return nil
 		}
 	}
@@ -0,0 +1,73 @@
+package parse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/altid/libs/service/commander"
+)
+
+func TestParseCmd(t *testing.T) {
+	for _, tc := range []struct {
+		cmd  string
+		name string
+		from string
+		args []string
+	}{
+		{"quit", "quit", "", nil},
+		{"open #altid", "open", "", []string{"#altid"}},
+		{"input #altid\n\thello world", "input", "#altid", []string{"hello", "world"}},
+	} {
+		name, from, args, err := ParseCmd(tc.cmd)
+		if err != nil {
+			t.Errorf("%q: %v", tc.cmd, err)
+			continue
+		}
+		if name != tc.name || from != tc.from || !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("%q: have %q %q %q", tc.cmd, name, from, args)
+		}
+	}
+
+	for _, bad := range []string{"", " ", "\n\tno name"} {
+		if _, _, _, err := ParseCmd(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+// Commands written with String read back the same
+func TestParseCmdString(t *testing.T) {
+	for _, cmd := range []*commander.Command{
+		{Name: "say", Args: []string{"x\ny"}},
+		{Name: "quit", From: "#altid"},
+		{Name: "quit", Args: []string{"bye"}},
+		{Name: "say", From: "#altid", Args: []string{"x\ny", "tab\there", "cr\r", `back\slash "quoted"`}},
+	} {
+		name, from, args, err := ParseCmd(cmd.String())
+		if err != nil {
+			t.Errorf("%q: %v", cmd.String(), err)
+			continue
+		}
+		if name != cmd.Name || from != cmd.From || !reflect.DeepEqual(args, cmd.Args) {
+			t.Errorf("%q: have %q %q %q", cmd.String(), name, from, args)
+		}
+	}
+}
+
+func TestParseCmdQuoteError(t *testing.T) {
+	for _, tc := range []struct {
+		cmd    string
+		offset int
+	}{
+		{"topic #chan\n\t\"hello world", 13},
+		{"topic #chan\nhello 'world", 18},
+		{"topic \"hello world", 6},
+	} {
+		_, _, _, err := ParseCmd(tc.cmd)
+		var qe *QuoteError
+		if !errors.As(err, &qe) || qe.Offset != tc.offset {
+			t.Errorf("%q: expected QuoteError at offset %d, have %v", tc.cmd, tc.offset, err)
+		}
+	}
+}
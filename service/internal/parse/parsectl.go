@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -9,10 +10,22 @@ import (
 	"github.com/altid/libs/service/commander"
 )
 
+const (
+	// Commands read before any heading
+	noHeading commander.ComGroup = -2
+	// Commands read after a heading we couldn't parse, which are dropped
+	badHeading commander.ComGroup = -3
+)
+
 /* Files are simple
 mything:
-	name <arg> #comment
-	name|othername <arg1> <arg2> #comment
+	name <arg> # comment
+	name|othername <arg1> <arg2> # comment
+
+WriteCtlFile writes the canonical form, which ParseCtlFile reads back to the same commands
+Each entry is a line of tab separated fields, all but the name optional
+	name|alias|alias	@from	<arg1> "<arg 2>"	# description
+Args are quoted as with commander.Quote, and the description runs to the end of the line
 */
 
 const (
@@ -24,256 +37,312 @@ const (
 	parserEntryArgs
 	parserEntryAlias
 	parserEntryDesc
+	parserEntryFrom
+	parserEndEntry
 )
 
-// Parse returns any commands found within the byte array
+// ParseCtlFile returns any commands found within the byte array
+// It stops at the first error, which is a *ParseError
 func ParseCtlFile(b []byte) ([]*commander.Command, error) {
+	cmdlist, errs := parseCtl(b, true)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return cmdlist, nil
+}
+
+// ParseCtlFileLenient is ParseCtlFile, but carries on past errors, skipping the lines they're found on
+// It returns every command it could read, along with ParseErrors if there were any
+// Commands under a heading which can't be read are skipped as well
+func ParseCtlFileLenient(b []byte) ([]*commander.Command, error) {
+	cmdlist, errs := parseCtl(b, false)
+	if len(errs) > 0 {
+		return cmdlist, errs
+	}
+	return cmdlist, nil
+}
+
+func parseCtl(b []byte, stop bool) ([]*commander.Command, ParseErrors) {
 	var cmdlist []*commander.Command
+	var errs ParseErrors
 	l := &lexer{
 		src:     b,
 		items:   make(chan item, 2),
-		state:   parseHeading,
-		heading: 9001,
+		state:   parseLineStart,
+		heading: noHeading,
 	}
 	for {
 		c, err := parseCtlFile(l)
+		if c != nil && c.Name != "" && c.Heading != noHeading && c.Heading != badHeading {
+			cmdlist = append(cmdlist, c)
+		}
 		switch err {
-		case io.EOF:
-			if c.Name != "" {
-				cmdlist = append(cmdlist, c)
-			}
-			return cmdlist, nil
 		case nil:
-			if c.Name != "" {
-				cmdlist = append(cmdlist, c)
-			}
-			continue
+		case io.EOF:
+			return cmdlist, errs
 		default:
-			return nil, err
+			errs = append(errs, err.(*ParseError))
+			if stop {
+				return nil, errs
+			}
 		}
 	}
 }
 
+// parseCtlFile reads the next command, returning io.EOF at the end of the file, or a *ParseError
 func parseCtlFile(l *lexer) (*commander.Command, error) {
 	cmd := &commander.Command{}
 	for {
 		i := l.next()
 		switch i.itemType {
 		case parserEOF:
-			cmd.Heading = l.heading
+			l.setHeading(cmd)
 			return cmd, io.EOF
 		case parserError:
-			return nil, fmt.Errorf("%s", i.data)
+			return nil, l.err
 		case parserHeading:
 			heading, err := headingFromString(i.data)
 			if err != nil {
-				return nil, err
+				l.heading = badHeading
+				pe := newParseError(l.src, i.pos, err.Error(), "a heading name")
+				pe.Err = err
+				return nil, pe
+			}
+			l.heading, l.headingName = heading, ""
+			if heading == commander.UnknownGroup {
+				l.headingName = string(i.data)
 			}
-			l.heading = heading
 			return cmd, nil
 		case parserNewEntry:
-			cmd.Heading = l.heading
+			if l.heading == noHeading {
+				return nil, newParseError(l.src, i.pos, "found command with no heading", "a heading")
+			}
+			l.setHeading(cmd)
+			return cmd, nil
+		case parserEndEntry:
+			l.setHeading(cmd)
 			return cmd, nil
 		case parserEntryName:
 			cmd.Name = string(i.data)
 		case parserEntryAlias:
 			cmd.Alias = append(cmd.Alias, string(i.data))
 		case parserEntryArgs:
-			cmd.Args = append(cmd.Args, string(i.data))
+			args, err := Split(string(i.data))
+			if err != nil {
+				return nil, argsError(l.src, i.pos, err)
+			}
+			cmd.Args = append(cmd.Args, args...)
 		case parserEntryDesc:
 			cmd.Description = string(i.data)
+		case parserEntryFrom:
+			cmd.From = string(i.data)
 		}
 	}
 }
 
-func parseHeading(l *lexer) stateFn {
-	for {
-		if l.peek() == ':' {
-			l.emit(parserHeading)
-		}
-		switch l.nextChar() {
-		case parserEOF:
-			l.src = []byte("found heading with no body")
-			l.start = 0
-			l.pos = len(l.src)
-			l.emit(parserError)
-			return nil
-		case '\n':
-			l.src = []byte("malformed header: no ending colon")
-			l.start = 0
-			l.pos = len(l.src)
-			l.emit(parserError)
-			return nil
-		case ':':
-			l.acceptRun(":\n\t ")
-			l.ignore()
-			return parseEntryName
-		}
+// argsError places an error from Split in the args beginning at offset
+func argsError(src []byte, offset int, err error) *ParseError {
+	var pe *ParseError
+	var qe *QuoteError
+	switch {
+	case !errors.As(err, &qe):
+		pe = newParseError(src, offset, err.Error(), "")
+	case qe.Quote == '\\':
+		pe = newParseError(src, offset+qe.Offset, "trailing backslash", "a character after it")
+	default:
+		pe = newParseError(src, offset+qe.Offset, fmt.Sprintf("unterminated %c quote", qe.Quote), fmt.Sprintf("a closing %c", qe.Quote))
 	}
+	pe.Err = err
+	return pe
+}
+
+// fail reports an error at offset, and skips the rest of the line
+func (l *lexer) fail(offset int, msg, expected string) stateFn {
+	l.err = newParseError(l.src, offset, msg, expected)
+	l.emit(parserError)
+	return skipLine
 }
 
-func parseMaybeHeading(l *lexer) stateFn {
+func skipLine(l *lexer) stateFn {
 	for {
 		switch l.nextChar() {
-		case parserEOF:
-			l.emit(parserEOF)
-			return nil
-		case ' ', '\t':
-			l.acceptRun("\t ")
+		case '\n', parserEOF:
 			l.ignore()
-			l.emit(parserNewEntry)
-			return parseEntryName
-		default:
-			l.backup()
-			return parseHeading
+			return parseLineStart
 		}
 	}
 }
 
-// Possible chars: " ", "<", "#", "\t", or a heading
-func parseEntryAmbiguous(l *lexer) stateFn {
+func parseHeading(l *lexer) stateFn {
 	for {
+		if l.peek() == ':' {
+			l.emit(parserHeading)
+		}
 		switch l.nextChar() {
 		case parserEOF:
-			l.emit(parserEOF)
-			return nil
+			l.heading = badHeading
+			return l.fail(l.pos, "found heading with no colon", "':' ending the heading")
 		case '\n':
-			// Whitespace means heading
-			if l.peek() == ' ' || l.peek() == '\t' {
-				l.acceptRun(" \t")
-				l.ignore()
-				l.emit(parserNewEntry)
-				return parseEntryName
+			l.heading = badHeading
+			return l.fail(l.pos-1, "malformed header: no ending colon", "':' ending the heading")
+		case ':':
+			if !l.accept("\n") && l.peek() != parserEOF {
+				l.heading = badHeading
+				return l.fail(l.pos, "malformed header: text after colon", "a newline")
 			}
-			l.accept("\n")
-			l.ignore()
-			return parseHeading
-		case '<':
-			l.accept("<")
-			l.ignore()
-			return parseEntryArg
-		case '#':
-			l.acceptRun("# \t")
-			l.ignore()
-			return parseEntryDesc
-		case ' ', '\t':
-			l.acceptRun(" \t")
 			l.ignore()
+			return parseLineStart
 		}
 	}
 }
 
-// Possible chars: " ", "|", "\n", entry name chars
-func parseEntryName(l *lexer) stateFn {
-	for {
-		if strings.IndexByte("| \t", l.peek()) >= 0 {
-			if l.pos > l.start {
-				l.emit(parserEntryName)
-			}
-		}
-		switch l.nextChar() {
-		case parserEOF:
-			if l.pos > l.start {
-				l.emit(parserEntryName)
-			}
-			l.emit(parserEOF)
-			return nil
-		case ' ', '\t':
-			l.acceptRun(" \t")
-			l.ignore()
-			return parseEntryAmbiguous
-		case '|':
-			l.accept("|")
-			l.ignore()
-			return parseEntryAlias
+// Entries are indented, anything else is a heading
+func parseLineStart(l *lexer) stateFn {
+	switch l.nextChar() {
+	case parserEOF:
+		l.emit(parserEOF)
+		return nil
+	case '\n':
+		l.ignore()
+		return parseLineStart
+	case ' ', '\t':
+		l.acceptRun(" \t")
+		l.ignore()
+		if l.peek() == '\n' || l.peek() == parserEOF {
+			return parseLineStart
 		}
+		l.emit(parserNewEntry)
+		return parseEntryName
 	}
+	l.backup()
+	l.emit(parserEndEntry)
+	return parseHeading
+}
+
+// Possible chars: "|", " ", "\t", "\n", entry name chars
+func parseEntryName(l *lexer) stateFn {
+	return parseName(l, parserEntryName)
 }
 
 func parseEntryAlias(l *lexer) stateFn {
+	return parseName(l, parserEntryAlias)
+}
+
+func parseName(l *lexer, t byte) stateFn {
 	for {
-		if strings.IndexByte("| \t", l.peek()) >= 0 {
-			if l.pos > l.start {
-				l.emit(parserEntryAlias)
+		if strings.IndexByte("| \t\n", l.peek()) >= 0 || l.peek() == parserEOF {
+			if l.pos == l.start {
+				return l.fail(l.pos, "empty command name", "a command name")
 			}
+			l.emit(t)
 		}
 		switch l.nextChar() {
 		case parserEOF:
-			if l.pos > l.start {
-				l.emit(parserEntryAlias)
-			}
 			l.emit(parserEOF)
 			return nil
 		case '\n':
-			l.accept("\n")
 			l.ignore()
-			return parseMaybeHeading
+			return parseLineStart
 		case ' ', '\t':
-			l.acceptRun("\t ")
-			l.ignore()
-			return parseEntryAmbiguous
+			l.backup()
+			return parseEntryField
 		case '|':
-			// We found another alias, stay in function
-			l.accept("|")
 			l.ignore()
 			return parseEntryAlias
 		}
 	}
 }
 
-func parseEntryArg(l *lexer) stateFn {
+// Possible chars: "@", "#", "\n", or the start of the args
+func parseEntryField(l *lexer) stateFn {
+	l.acceptRun(" \t")
+	l.ignore()
+	switch l.nextChar() {
+	case parserEOF:
+		l.emit(parserEOF)
+		return nil
+	case '\n':
+		l.ignore()
+		return parseLineStart
+	case '@':
+		l.ignore()
+		return parseEntryFrom
+	case '#':
+		l.accept(" ")
+		l.ignore()
+		return parseEntryDesc
+	}
+	l.backup()
+	return parseEntryArgs
+}
+
+// Args run to the next field, or to an unquoted " #" starting the description, as in the older space separated form
+// They can't hold a tab, so a quote left open ends with the field, for Split to report
+func parseEntryArgs(l *lexer) stateFn {
+	var quote byte
 	for {
-		if l.peek() == '>' {
-			if l.pos > l.start {
-				l.emit(parserEntryArgs)
+		switch c := l.peek(); {
+		case c == '\t' || c == '\n' || c == parserEOF:
+			l.emit(parserEntryArgs)
+			return parseEntryField
+		case quote == 0 && bytes.HasPrefix(l.src[l.pos:], []byte(" #")):
+			l.emit(parserEntryArgs)
+			return parseEntryField
+		case c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == '\\' && quote != '\'':
+			// Skip whatever is escaped, unless that ends the field
+			l.nextChar()
+			if c := l.peek(); c == '\t' || c == '\n' || c == parserEOF {
+				continue
 			}
 		}
-		switch l.nextChar() {
-		case parserEOF:
-			l.emit(parserError)
-			return nil
-		case '\n':
-			l.accept("\n")
-			l.ignore()
-			return parseMaybeHeading
-		case '>':
-			l.acceptRun("> ")
-			l.ignore()
-			return parseEntryAmbiguous
+		l.nextChar()
+	}
+}
+
+func parseEntryFrom(l *lexer) stateFn {
+	return parseField(l, parserEntryFrom)
+}
+
+func parseField(l *lexer, t byte) stateFn {
+	for {
+		switch l.peek() {
+		case '\t', '\n', parserEOF:
+			l.emit(t)
+			return parseEntryField
 		}
+		l.nextChar()
 	}
 }
 
+// The description takes the rest of the line, tabs and all
 func parseEntryDesc(l *lexer) stateFn {
 	for {
-		if l.peek() == '\n' {
-			if l.pos > l.start {
-				l.emit(parserEntryDesc)
-			}
-		}
-		switch l.nextChar() {
-		case parserEOF:
+		switch l.peek() {
+		case '\n', parserEOF:
 			l.emit(parserEntryDesc)
-			return nil
-		case '\n':
-			l.accept("\n")
-			l.ignore()
-			return parseMaybeHeading
+			return parseEntryField
 		}
+		l.nextChar()
 	}
 }
 
+// setHeading places cmd under the current heading
+func (l *lexer) setHeading(cmd *commander.Command) {
+	cmd.Heading, cmd.HeadingName = l.heading, l.headingName
+}
+
+// headingFromString returns the group named by a heading
+// Headings which aren't registered are UnknownGroup, so untrusted input can't add to the registry
 func headingFromString(b []byte) (commander.ComGroup, error) {
-	switch string(b) {
-	case "general":
-		return commander.DefaultGroup, nil
-	case "media":
-		return commander.MediaGroup, nil
-	case "emotes":
-		return commander.ActionGroup, nil
-	case "service":
-		return commander.ServiceGroup, nil
-	default:
-		return 0, errors.New("unknown heading")
+	if g, ok := commander.GroupByName(string(b)); ok {
+		return g, nil
+	}
+	if !commander.ValidGroupName(string(b)) {
+		return 0, fmt.Errorf("invalid group name %q", b)
 	}
+	return commander.UnknownGroup, nil
 }
@@ -0,0 +1,265 @@
+package parse
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+
+	fuzz "github.com/google/gofuzz"
+
+	"github.com/altid/libs/service/commander"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func goldenCommands() []*commander.Command {
+	files := commander.MustRegisterGroup("files", "Files", 250)
+	cmds := append([]*commander.Command(nil), commander.DefaultCommands...)
+	return append(cmds,
+		&commander.Command{Name: "me", Alias: []string{"act", "emote"}, Heading: commander.ActionGroup, Args: []string{"<action>"}, Description: "Send an action & <more>"},
+		&commander.Command{Name: "kick", Heading: commander.ActionGroup, From: "#altid", Args: []string{"<nick>", "<reason with spaces>"}},
+		&commander.Command{Name: "tag", Heading: commander.ActionGroup, Args: []string{"#hash", "@at", `say "hi"`, "it's", `C:\dir`, ""}},
+		&commander.Command{Name: "upload", Heading: files, Description: "  # spaces,\ttabs and # kept  "},
+		&commander.Command{Name: "restart", Heading: commander.ServiceGroup},
+	)
+}
+
+func TestCtlGolden(t *testing.T) {
+	cmds := goldenCommands()
+	var b bytes.Buffer
+	if err := WriteCtlFile(&b, cmds); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := "testdata/commands.ctl"
+	if *update {
+		if err := os.WriteFile(golden, b.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("output differs from %s, have\n%s", golden, b.Bytes())
+	}
+
+	have, err := ParseCtlFile(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSame(t, have, cmds)
+}
+
+func TestCtlParams(t *testing.T) {
+	cmds := []*commander.Command{{
+		Name:    "kick",
+		Heading: commander.ActionGroup,
+		Params:  []commander.Param{{Name: "nick"}, {Name: "reason", Type: commander.RestParam, Optional: true}},
+	}}
+	var b bytes.Buffer
+	if err := WriteCtlFile(&b, cmds); err != nil {
+		t.Fatal(err)
+	}
+	have, err := ParseCtlFile(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"<nick>", "<[reason:line]>"}; len(have) != 1 || !reflect.DeepEqual(have[0].Args, want) {
+		t.Errorf("params should read back as args, have %q", have[0].Args)
+	}
+}
+
+func TestCtlEmpty(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteCtlFile(&b, nil); err != nil || b.Len() != 0 {
+		t.Errorf("have %q, %v", b.String(), err)
+	}
+	if cmds, err := ParseCtlFile(nil); err != nil || len(cmds) != 0 {
+		t.Errorf("have %v, %v", cmds, err)
+	}
+}
+
+func TestCtlInvalid(t *testing.T) {
+	for _, cmd := range []*commander.Command{
+		nil,
+		{Name: ""},
+		{Name: "two words"},
+		{Name: "a|b"},
+		{Name: "ok", Alias: []string{"no\tway"}},
+		{Name: "ok", From: "#two words"},
+		{Name: "ok", Args: []string{"line\nbreak"}},
+		{Name: "ok", Description: "two\nlines"},
+		{Name: "ok", Heading: 9001},
+	} {
+		if err := WriteCtlFile(&bytes.Buffer{}, []*commander.Command{cmd}); err == nil {
+			t.Errorf("%+v: expected error", cmd)
+		}
+	}
+
+	for _, bad := range []string{
+		"\topen\n",
+		"general\n\topen\n",
+		"general:\n\topen\t\"unterminated\n",
+		"general:\n\t|alias\n",
+	} {
+		if _, err := ParseCtlFile([]byte(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+// Listings written before the canonical form still read
+func TestParseCtlFileLegacy(t *testing.T) {
+	b := []byte("general:\n\topen\t<buffer> \t# Open a buffer\n\tquit\t# Exits the client\nemotes:\n\tme|act\t<action> \n")
+	have, err := ParseCtlFile(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSame(t, have, []*commander.Command{
+		{Name: "open", Heading: commander.DefaultGroup, Args: []string{"<buffer>"}, Description: "Open a buffer"},
+		{Name: "quit", Heading: commander.DefaultGroup, Description: "Exits the client"},
+		{Name: "me", Alias: []string{"act"}, Heading: commander.ActionGroup, Args: []string{"<action>"}},
+	})
+
+	// The space separated form, where an unquoted " #" starts the description
+	b = []byte("general:\n\topen <buffer> # Open and change\n\tlink <a> \"<b #c>\"  #Link them\n\ttag a#b\n")
+	if have, err = ParseCtlFile(b); err != nil {
+		t.Fatal(err)
+	}
+	checkSame(t, have, []*commander.Command{
+		{Name: "open", Heading: commander.DefaultGroup, Args: []string{"<buffer>"}, Description: "Open and change"},
+		{Name: "link", Heading: commander.DefaultGroup, Args: []string{"<a>", "<b #c>"}, Description: "Link them"},
+		{Name: "tag", Heading: commander.DefaultGroup, Args: []string{"a#b"}},
+	})
+}
+
+// Any command which writes must read back the same, and any command with valid fields must write
+func TestCtlRoundTrip(t *testing.T) {
+	headings := commander.Groups()
+	f := fuzz.New().NilChance(.2).NumElements(0, 4)
+	for i := 0; i < 2000; i++ {
+		var name, from, desc string
+		var alias, args []string
+		var heading uint8
+		f.Fuzz(&name)
+		f.Fuzz(&from)
+		f.Fuzz(&desc)
+		f.Fuzz(&alias)
+		f.Fuzz(&args)
+		f.Fuzz(&heading)
+		cmd := &commander.Command{
+			Name:        name,
+			Alias:       alias,
+			From:        from,
+			Args:        args,
+			Description: desc,
+			Heading:     headings[int(heading)%len(headings)],
+		}
+
+		var b bytes.Buffer
+		if err := WriteCtlFile(&b, []*commander.Command{cmd}); err == nil {
+			roundTrip(t, cmd, b.Bytes())
+		}
+
+		cmd.Name = sanitize(name, unicode.IsSpace, "name")
+		for i := range cmd.Alias {
+			cmd.Alias[i] = sanitize(cmd.Alias[i], unicode.IsSpace, "alias")
+		}
+		cmd.From = sanitize(from, unicode.IsSpace, "")
+		for i := range cmd.Args {
+			cmd.Args[i] = sanitize(cmd.Args[i], nil, "")
+		}
+		cmd.Description = sanitize(desc, nil, "")
+		b.Reset()
+		if err := WriteCtlFile(&b, []*commander.Command{cmd}); err != nil {
+			t.Fatalf("%+v: %v", cmd, err)
+		}
+		roundTrip(t, cmd, b.Bytes())
+	}
+}
+
+func roundTrip(t *testing.T, cmd *commander.Command, b []byte) {
+	t.Helper()
+	have, err := ParseCtlFile(b)
+	if err != nil {
+		t.Fatalf("%q: %v", b, err)
+	}
+	checkSame(t, have, []*commander.Command{cmd})
+}
+
+// sanitize drops what can't be written from s, and anything matching drop, using def if nothing is left
+func sanitize(s string, drop func(rune) bool, def string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '|' && def != "" || unicode.IsControl(r) || drop != nil && drop(r) {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func checkSame(t *testing.T, have, want []*commander.Command) {
+	t.Helper()
+	if len(have) != len(want) {
+		t.Fatalf("have %d commands, want %d", len(have), len(want))
+	}
+	for i, w := range want {
+		h := have[i]
+		if h.Name != w.Name || h.Heading != w.Heading || h.HeadingName != w.HeadingName || h.From != w.From || h.Description != w.Description ||
+			!sameList(h.Alias, w.Alias) || !sameList(h.Args, w.Args) {
+			t.Errorf("have %+v, want %+v", h, w)
+		}
+	}
+}
+
+// Nil and empty lists read back the same
+func sameList(a, b []string) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
+
+// Headings which aren't registered read back by name, and are never registered
+func TestCtlUnknownHeading(t *testing.T) {
+	registered := len(commander.Groups())
+	cmds := []*commander.Command{
+		{Name: "summon", Heading: commander.UnknownGroup, HeadingName: "wizardry"},
+		{Name: "banish", Heading: commander.UnknownGroup, HeadingName: "wizardry"},
+		{Name: "open", Heading: commander.DefaultGroup},
+		{Name: "brew", Heading: commander.UnknownGroup, HeadingName: "alchemy"},
+	}
+	var b bytes.Buffer
+	if err := WriteCtlFile(&b, cmds); err != nil {
+		t.Fatal(err)
+	}
+	if want := "wizardry:\n\tsummon\n\tbanish\ngeneral:\n\topen\nalchemy:\n\tbrew\n"; b.String() != want {
+		t.Errorf("have %q, want %q", b.String(), want)
+	}
+	have, err := ParseCtlFile(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSame(t, have, cmds)
+
+	for i := 0; i < 100; i++ {
+		if _, err := ParseCtlFile([]byte(fmt.Sprintf("junk%d:\n\tcmd\n", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(commander.Groups()) != registered {
+		t.Errorf("parsing grew the registry from %d to %d groups", registered, len(commander.Groups()))
+	}
+
+	bad := []*commander.Command{{Name: "lost", Heading: commander.UnknownGroup, HeadingName: "two words"}}
+	if err := WriteCtlFile(&b, bad); err == nil {
+		t.Error("expected error writing an invalid heading")
+	}
+}
@@ -0,0 +1,107 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuoteError is returned from Split for a quote with no closing quote, or a trailing backslash
+type QuoteError struct {
+	// Quote is the unclosed quote, or a backslash
+	Quote byte
+	// Offset is the byte offset of Quote in the input
+	Offset int
+}
+
+func (e *QuoteError) Error() string {
+	if e.Quote == '\\' {
+		return fmt.Sprintf("trailing backslash at offset %d", e.Offset)
+	}
+	return fmt.Sprintf("unterminated %c quote at offset %d", e.Quote, e.Offset)
+}
+
+// Split breaks s into arguments the way a shell would
+// Arguments are separated by whitespace, which a backslash or quotes keep within an argument
+// Inside double quotes, only \", \\, \n, \r and \t are escapes; inside single quotes, nothing is
+func Split(s string) ([]string, error) {
+	return SplitN(s, -1)
+}
+
+// SplitN is Split, returning at most n arguments
+// If there would be more, the last argument is the rest of s as written, quotes and all, less any trailing whitespace
+func SplitN(s string, n int) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	i := 0
+	for {
+		// Skip to the next argument
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i == len(s) {
+			return args, nil
+		}
+		if n > 0 && len(args) == n-1 {
+			return append(args, strings.TrimRight(s[i:], " \t\n")), nil
+		}
+
+		arg.Reset()
+		for i < len(s) && !isSpace(s[i]) {
+			switch c := s[i]; c {
+			case '\\':
+				if i+1 == len(s) {
+					return nil, &QuoteError{Quote: c, Offset: i}
+				}
+				arg.WriteByte(s[i+1])
+				i += 2
+			case '\'':
+				end := strings.IndexByte(s[i+1:], '\'')
+				if end < 0 {
+					return nil, &QuoteError{Quote: c, Offset: i}
+				}
+				arg.WriteString(s[i+1 : i+1+end])
+				i += end + 2
+			case '"':
+				start := i
+				i++
+				for {
+					if i == len(s) {
+						return nil, &QuoteError{Quote: c, Offset: start}
+					}
+					if s[i] == '"' {
+						i++
+						break
+					}
+					if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\nrt`, s[i+1]) >= 0 {
+						i++
+						arg.WriteByte(unescape(s[i]))
+					} else {
+						arg.WriteByte(s[i])
+					}
+					i++
+				}
+			default:
+				arg.WriteByte(c)
+				i++
+			}
+		}
+		args = append(args, arg.String())
+	}
+}
+
+// unescape returns the byte a double quoted backslash escape stands for
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	}
+	return c
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
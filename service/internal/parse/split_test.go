@@ -0,0 +1,78 @@
+package parse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  one\ttwo\n", []string{"one", "two"}},
+		{`"hello world" again`, []string{"hello world", "again"}},
+		{`'it''s "fine"'`, []string{`its "fine"`}},
+		{`hello\ world`, []string{"hello world"}},
+		{`"say \"hi\" \\ \q"`, []string{`say "hi" \ \q`}},
+		{`"one\ntwo\r\tthree" \n`, []string{"one\ntwo\r\tthree", "n"}},
+		{`a"b c"'d e'`, []string{"ab cd e"}},
+		{`"" ''`, []string{"", ""}},
+	} {
+		have, err := Split(tc.in)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%q: have %q, want %q", tc.in, have, tc.want)
+		}
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		quote  byte
+		offset int
+	}{
+		{`topic "hello world`, '"', 6},
+		{`it's`, '\'', 2},
+		{`a "b\"`, '"', 2},
+		{`trailing\`, '\\', 8},
+	} {
+		_, err := Split(tc.in)
+		var qe *QuoteError
+		if !errors.As(err, &qe) {
+			t.Errorf("%q: expected QuoteError, have %v", tc.in, err)
+			continue
+		}
+		if qe.Quote != tc.quote || qe.Offset != tc.offset {
+			t.Errorf("%q: have %v", tc.in, qe)
+		}
+	}
+}
+
+func TestSplitN(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		n    int
+		want []string
+	}{
+		{`#chan  hello   "world" `, 2, []string{"#chan", `hello   "world"`}},
+		{`nick`, 2, []string{"nick"}},
+		{`one two three`, 1, []string{"one two three"}},
+		{`one two three`, 0, []string{"one", "two", "three"}},
+	} {
+		have, err := SplitN(tc.in, tc.n)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%q %d: have %q, want %q", tc.in, tc.n, have, tc.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package parse
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/altid/libs/service/commander"
+)
+
+// WriteCtlFile writes cmdlist in the canonical form read by ParseCtlFile, with a heading wherever the group changes
+// Params are written in place of Args, as they're shown to the user; they read back as Args
+// It returns an error for any command which wouldn't read back the same, such as a name with spaces or a description over two lines
+func WriteCtlFile(w io.Writer, cmdlist []*commander.Command) error {
+	bw := bufio.NewWriter(w)
+	var curr string
+	for i, cmd := range cmdlist {
+		if cmd == nil {
+			return fmt.Errorf("nil command")
+		}
+		name, err := headingName(cmd)
+		if err != nil {
+			return err
+		}
+		if i == 0 || name != curr {
+			fmt.Fprintf(bw, "%s:\n", name)
+			curr = name
+		}
+		if err := writeEntry(bw, cmd); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// headingName returns the heading cmd is written under, which for UnknownGroup is its HeadingName
+func headingName(cmd *commander.Command) (string, error) {
+	if cmd.Heading == commander.UnknownGroup {
+		if !commander.ValidGroupName(cmd.HeadingName) {
+			return "", fmt.Errorf("%s: invalid heading %q", cmd.Name, cmd.HeadingName)
+		}
+		return cmd.HeadingName, nil
+	}
+	g, ok := cmd.Heading.Group()
+	if !ok {
+		return "", fmt.Errorf("%s: unknown command group %d", cmd.Name, cmd.Heading)
+	}
+	return g.Name, nil
+}
+
+func writeEntry(w *bufio.Writer, cmd *commander.Command) error {
+	if !validName(cmd.Name) {
+		return fmt.Errorf("invalid command name %q", cmd.Name)
+	}
+	w.WriteString("\t" + cmd.Name)
+	for _, alias := range cmd.Alias {
+		if !validName(alias) {
+			return fmt.Errorf("%s: invalid alias %q", cmd.Name, alias)
+		}
+		w.WriteString("|" + alias)
+	}
+
+	if cmd.From != "" {
+		if strings.IndexFunc(cmd.From, isSpaceOrControl) >= 0 {
+			return fmt.Errorf("%s: invalid buffer %q", cmd.Name, cmd.From)
+		}
+		w.WriteString("\t@" + cmd.From)
+	}
+
+	args := cmd.Args
+	if len(cmd.Params) > 0 {
+		args = nil
+		for _, p := range cmd.Params {
+			args = append(args, p.String())
+		}
+	}
+	for i, arg := range args {
+		if strings.IndexFunc(arg, unicode.IsControl) >= 0 {
+			return fmt.Errorf("%s: invalid argument %q", cmd.Name, arg)
+		}
+		if i == 0 {
+			w.WriteByte('\t')
+		} else {
+			w.WriteByte(' ')
+		}
+		w.WriteString(quoteArg(arg))
+	}
+
+	if cmd.Description != "" {
+		if strings.IndexFunc(cmd.Description, func(r rune) bool { return r != '\t' && unicode.IsControl(r) }) >= 0 {
+			return fmt.Errorf("%s: invalid description %q", cmd.Name, cmd.Description)
+		}
+		w.WriteString("\t# " + cmd.Description)
+	}
+	w.WriteByte('\n')
+	return nil
+}
+
+// quoteArg is commander.Quote, also quoting args which would otherwise be read as the start of another field
+func quoteArg(arg string) string {
+	if strings.HasPrefix(arg, "@") || strings.HasPrefix(arg, "#") {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+	}
+	return commander.Quote(arg)
+}
+
+// Names end at whitespace or a '|', and can't be empty
+func validName(name string) bool {
+	return name != "" && !strings.ContainsRune(name, '|') && strings.IndexFunc(name, isSpaceOrControl) < 0
+}
+
+func isSpaceOrControl(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
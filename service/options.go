@@ -0,0 +1,169 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/altid/libs/service/callback"
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/transport"
+)
+
+// Option configures a Service in Register
+type Option func(*Service) error
+
+// WithLogger sets the logger used by the service and its config
+// Records carry the service name, and where relevant the buffer, command and byte count
+// By default, slog.Default is used
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Service) error {
+		if l == nil {
+			return errors.New("nil logger")
+		}
+		s.logger = l
+		return nil
+	}
+}
+
+// WithTransport sets the Transport used to reach the Altid server
+// By default, transport.Default is used
+func WithTransport(t transport.Transport) Option {
+	return func(s *Service) error {
+		if t == nil {
+			return errors.New("nil transport")
+		}
+		s.tr = t
+		return nil
+	}
+}
+
+// WithCommands sets the commands the service offers to clients
+func WithCommands(cmds []*commander.Command) Option {
+	return func(s *Service) error {
+		for _, cmd := range cmds {
+			if cmd == nil {
+				return errors.New("nil command")
+			}
+			if cmd.Name == "" || strings.IndexFunc(cmd.Name, unicode.IsSpace) >= 0 {
+				return fmt.Errorf("invalid command name %q", cmd.Name)
+			}
+			if _, ok := cmd.Heading.Group(); !ok {
+				return fmt.Errorf("%s: unregistered command group %d", cmd.Name, cmd.Heading)
+			}
+			for _, name := range append([]string{cmd.Name}, cmd.Alias...) {
+				if commander.Reserved(name) {
+					return fmt.Errorf("%s: %q is reserved for the service library", cmd.Name, name)
+				}
+			}
+			if e := cmd.CheckParams(); e != nil {
+				return e
+			}
+		}
+		s.cmds = cmds
+		return nil
+	}
+}
+
+// WithCallbacks sets the callbacks for the service, and is required
+func WithCallbacks(cb callback.Callback) Option {
+	return func(s *Service) error {
+		if cb == nil {
+			return errors.New("nil callbacks")
+		}
+		s.cb = cb
+		return nil
+	}
+}
+
+// WithConfig sets a pointer to the service's config struct, which is marshalled again with config.Marshal on reload
+// configFile is passed to config.Marshal, and may be empty to use the default
+func WithConfig(conf any, configFile string) Option {
+	return func(s *Service) error {
+		v := reflect.ValueOf(conf)
+		if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("config must be a non-nil pointer to a struct, have %T", conf)
+		}
+		s.conf = conf
+		s.confFile = configFile
+		return nil
+	}
+}
+
+// WithForeground keeps the service in the foreground, instead of backgrounding it in Listen
+func WithForeground() Option {
+	return func(s *Service) error {
+		s.fg = true
+		return nil
+	}
+}
+
+// WithTakeover shuts down any running instance of the service in Register, instead of failing
+// The old instance is sent SIGTERM, and Register waits for it to exit
+func WithTakeover() Option {
+	return func(s *Service) error {
+		s.takeover = true
+		return nil
+	}
+}
+
+// WithSignals sets which signals the service handles, and the lifecycle command each runs: shutdown, reload, restart or dump
+// Signals run through the same path as commands from the ctl file; dump logs the state of the service
+// By default on Unix, SIGTERM and SIGINT shut down, SIGHUP reloads and SIGUSR1 dumps; an empty map handles no signals
+func WithSignals(sigs map[os.Signal]string) Option {
+	return func(s *Service) error {
+		s.signals = make(map[os.Signal]string, len(sigs))
+		for sig, cmd := range sigs {
+			if !signalCommands[cmd] {
+				return fmt.Errorf("signal %v: unknown command %q", sig, cmd)
+			}
+			s.signals[sig] = cmd
+		}
+		return nil
+	}
+}
+
+// WithConcurrency limits how many input handlers run at once across all buffers, and how much input may wait on a single buffer
+// Input for one buffer is always handled in order; by default 16 handlers may run, with 128 inputs waiting
+func WithConcurrency(workers, queue int) Option {
+	return func(s *Service) error {
+		if workers < 1 || queue < 1 {
+			return fmt.Errorf("invalid concurrency %d workers, %d queued", workers, queue)
+		}
+		s.workers = workers
+		s.queue = queue
+		return nil
+	}
+}
+
+// WithHandlerTimeout sets a deadline on the context handed to a callback.ContextHandler for each input
+// By default there is none
+func WithHandlerTimeout(d time.Duration) Option {
+	return func(s *Service) error {
+		if d <= 0 {
+			return fmt.Errorf("invalid handler timeout %v", d)
+		}
+		s.timeout = d
+		return nil
+	}
+}
+
+// validName makes sure name can be used as a service, and as a path element on the server
+func validName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("service name cannot be empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid service name %q", name)
+	case strings.ContainsAny(name, "/\x00"):
+		return fmt.Errorf("service name %q cannot contain a slash or NUL", name)
+	case strings.IndexFunc(name, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0:
+		return fmt.Errorf("service name %q cannot contain whitespace or control characters", name)
+	}
+	return nil
+}
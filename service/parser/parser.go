@@ -1,10 +1,30 @@
 package parser
 
 import (
+	"io"
+
 	"github.com/altid/libs/service/commander"
 	"github.com/altid/libs/service/internal/parse"
 )
 
+// ParseError describes where and why a ctl file failed to parse
+type ParseError = parse.ParseError
+
+// ParseErrors is every error found by ParseCtrlFileLenient
+type ParseErrors = parse.ParseErrors
+
+// ParseCtrlFile returns the commands listed in b, stopping at the first error, which is a *ParseError
 func ParseCtrlFile(b []byte) ([]*commander.Command, error) {
 	return parse.ParseCtlFile(b)
 }
+
+// ParseCtrlFileLenient returns every command it can read from b, skipping any lines with errors
+// The errors are returned together as ParseErrors
+func ParseCtrlFileLenient(b []byte) ([]*commander.Command, error) {
+	return parse.ParseCtlFileLenient(b)
+}
+
+// WriteCtrlFile writes cmdlist in the form read by ParseCtrlFile
+func WriteCtrlFile(w io.Writer, cmdlist []*commander.Command) error {
+	return parse.WriteCtlFile(w, cmdlist)
+}
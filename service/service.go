@@ -2,49 +2,133 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
 
-	"github.com/altid/libs/threads"
+	"github.com/altid/libs/config"
 	"github.com/altid/libs/service/callback"
 	"github.com/altid/libs/service/commander"
 	"github.com/altid/libs/service/internal/control"
+	"github.com/altid/libs/service/transport"
+	"github.com/altid/libs/threads"
+)
+
+// Errors returned from Listen, saying why the service stopped
+var (
+	// ErrShutdown is returned after the service was asked to shut down
+	// Its open writers were closed and its buffers deleted first
+	ErrShutdown = control.ErrShutdown
+	// ErrRestart wraps the error from a failed attempt to re-execute the service
+	// A successful restart doesn't return from Listen
+	ErrRestart = control.ErrRestart
+	// ErrClosed is returned when the server closed the connection to the service
+	ErrClosed = control.ErrClosed
 )
 
 type Service struct {
-	fg  bool
-	ctx context.Context
-	name string
-	cb callback.Callback
-	cmds []*commander.Command
-	ctl *control.Control
+	fg       bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	name     string
+	cb       callback.Callback
+	cmds     []*commander.Command
+	ctl      *control.Control
+	tr       transport.Transport
+	conf     any
+	confFile string
+	workers  int
+	queue    int
+	timeout  time.Duration
+	logger   *slog.Logger
+	takeover bool
+	signals  map[os.Signal]string
 }
 
-func Register(ctx context.Context, name string, fg bool) (*Service, error) {
-	return &Service{
-		ctx: ctx,
-		name: name,
-		fg: fg,
-	}, nil
+// Register returns a Service with the given name, configured with opts
+// WithCallbacks is required; an invalid name or option returns an error
+// Only one instance of a service may run under a name; if another holds the lock, the error wraps a *threads.LockedError naming its pid
+//
+//	svc, err := service.Register(ctx, "zzyzx",
+//		service.WithCallbacks(&myservice{}),
+//		service.WithCommands(cmds),
+//		service.WithForeground(),
+//	)
+func Register(ctx context.Context, name string, opts ...Option) (*Service, error) {
+	if e := validName(name); e != nil {
+		return nil, e
+	}
+	s := &Service{
+		name:    name,
+		logger:  slog.Default(),
+		signals: defaultSignals(),
+	}
+	for _, opt := range opts {
+		if e := opt(s); e != nil {
+			return nil, fmt.Errorf("service %s: %w", name, e)
+		}
+	}
+	if s.cb == nil {
+		return nil, fmt.Errorf("service %s: no callbacks set, use WithCallbacks", name)
+	}
+	threads.SetName(name)
+	if e := threads.Lock(s.takeover); e != nil {
+		return nil, fmt.Errorf("service %s: %w", name, e)
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	return s, nil
 }
 
-func (s *Service) SetCommands(cmds []*commander.Command) {
-	s.cmds = cmds
+// SetCommands replaces the commands set with WithCommands, checking them the same way
+// It must be called before Listen
+func (s *Service) SetCommands(cmds []*commander.Command) error {
+	return WithCommands(cmds)(s)
 }
 
-func (s *Service) SetCallbacks(cb callback.Callback) {
-	s.cb = cb
+// SetCallbacks replaces the callbacks set with WithCallbacks, returning an error if cb is nil
+// It must be called before Listen
+func (s *Service) SetCallbacks(cb callback.Callback) error {
+	return WithCallbacks(cb)(s)
 }
 
+// Context returns the service's context, which is cancelled when the service shuts down or Listen otherwise returns
+// Handlers should use it to abandon in-flight work
+func (s *Service) Context() context.Context {
+	return s.ctx
+}
+
+// Listen connects to the server and runs the service until it stops
+// The returned error says why the service stopped; see ErrShutdown, ErrRestart and ErrClosed
 func (s *Service) Listen() error {
-	return threads.Start(func () error{
+	err := threads.Start(func() error {
 		// Make sure we call everything after the fork to set up our stack
-		ctl, err := control.ConnectService(s.ctx, s.name)
+		ctl, err := control.ConnectService(s.ctx, s.name, s.tr)
 		if err != nil {
 			return err
 		}
 		ctl.SetCommands(s.cmds)
 		ctl.SetCallbacks(s.cb)
+		ctl.SetReload(s.reload)
+		ctl.SetConcurrency(s.workers, s.queue)
+		ctl.SetHandlerTimeout(s.timeout)
+		ctl.SetLogger(s.logger.With("service", s.name))
 		s.ctl = ctl
+		defer s.notify(ctl)()
 		return s.ctl.Listen()
 	}, s.fg)
-	//return threads.Start(s.ctl.Listen, s.fg)
+	s.cancel()
+	// Start has released our lock and pidfile, so the new copy starts afresh
+	if errors.Is(err, ErrRestart) {
+		return fmt.Errorf("%w: %v", ErrRestart, threads.Restart())
+	}
+	return err
+}
+
+func (s *Service) reload() error {
+	if s.conf == nil {
+		return nil
+	}
+	return config.Marshal(s.conf, s.name, s.confFile, s.logger)
 }
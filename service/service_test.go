@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/altid/libs/markup"
+	"github.com/altid/libs/service/commander"
+	"github.com/altid/libs/service/controller"
+	"github.com/altid/libs/threads"
+)
+
+type testService struct{}
+
+func (t *testService) Connect(string) error               { return nil }
+func (t *testService) Handle(string, *markup.Lexer) error { return nil }
+func (t *testService) Start(controller.Controller) error  { return nil }
+
+func TestRegister(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	defer threads.Unlock()
+
+	ctx := context.Background()
+	svc, err := Register(ctx, "zzyzx", WithCallbacks(&testService{}), WithForeground())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !svc.fg || svc.cb == nil {
+		t.Error("options not applied")
+	}
+
+	if err := svc.SetCallbacks(nil); err == nil || svc.cb == nil {
+		t.Error("nil callbacks accepted")
+	}
+	if err := svc.SetCommands([]*commander.Command{{Name: "ban", Heading: 9001}}); err == nil || svc.cmds != nil {
+		t.Error("commands not checked")
+	}
+	if err := svc.SetCommands([]*commander.Command{{Name: "ban"}}); err != nil || len(svc.cmds) != 1 {
+		t.Errorf("commands not set: %v", err)
+	}
+
+	threads.Unlock()
+	if _, err := Register(ctx, "zzyzx", WithCallbacks(&testService{})); err != nil {
+		t.Fatal(err)
+	}
+	var le *threads.LockedError
+	if _, err := Register(ctx, "zzyzx", WithCallbacks(&testService{})); !errors.As(err, &le) || le.Pid != os.Getpid() {
+		t.Errorf("expected a LockedError naming our pid, have %v", err)
+	}
+	// Release the lock, so the errors below come from checking the name and options
+	threads.Unlock()
+
+	conf := struct{ Address string }{"127.0.0.1"}
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"", []Option{WithCallbacks(&testService{})}},
+		{"my service", []Option{WithCallbacks(&testService{})}},
+		{"../zzyzx", []Option{WithCallbacks(&testService{})}},
+		{"zzyzx", nil},
+		{"zzyzx", []Option{WithCallbacks(nil)}},
+		{"zzyzx", []Option{WithCallbacks(&testService{}), WithConfig(conf, "")}},
+		{"zzyzx", []Option{WithCallbacks(&testService{}), WithCommands([]*commander.Command{{Name: "bad name"}})}},
+		{"zzyzx", []Option{WithCallbacks(&testService{}), WithCommands([]*commander.Command{{Name: "ban", Heading: 9001}})}},
+		{"zzyzx", []Option{WithCallbacks(&testService{}), WithConcurrency(0, 1)}},
+		{"zzyzx", []Option{WithCallbacks(&testService{}), WithCommands([]*commander.Command{{Name: "complete"}})}},
+		{"zzyzx", []Option{WithCallbacks(&testService{}), WithCommands([]*commander.Command{{Name: "abort", Alias: []string{"cancel"}}})}},
+	} {
+		_, err := Register(ctx, tc.name, tc.opts...)
+		if err == nil {
+			t.Errorf("expected error registering %q with %d options", tc.name, len(tc.opts))
+		}
+		if errors.As(err, &le) {
+			t.Fatalf("registering %q: have %v, expected the name or options to be rejected", tc.name, err)
+		}
+	}
+}
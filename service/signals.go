@@ -0,0 +1,43 @@
+package service
+
+import (
+	"os"
+	"os/signal"
+
+	"github.com/altid/libs/service/internal/control"
+)
+
+// signalCommands are the lifecycle commands a signal may be mapped to
+// dump logs the state of the service
+var signalCommands = map[string]bool{
+	"shutdown": true,
+	"reload":   true,
+	"restart":  true,
+	"dump":     true,
+}
+
+// notify runs the command mapped to each signal the service receives, until stop is called
+func (s *Service) notify(ctl *control.Control) (stop func()) {
+	if len(s.signals) == 0 {
+		return func() {}
+	}
+	sigs := make(chan os.Signal, 1)
+	for sig := range s.signals {
+		signal.Notify(sigs, sig)
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				ctl.Signal(s.signals[sig])
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
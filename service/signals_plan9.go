@@ -0,0 +1,13 @@
+package service
+
+import (
+	"os"
+	"syscall"
+)
+
+func defaultSignals() map[os.Signal]string {
+	return map[os.Signal]string{
+		os.Interrupt:           "shutdown",
+		syscall.Note("hangup"): "reload",
+	}
+}
@@ -0,0 +1,116 @@
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/altid/libs/markup"
+	"github.com/altid/libs/service/controller"
+	"github.com/altid/libs/service/transport"
+)
+
+type signalService struct {
+	started chan controller.Controller
+	reloads chan bool
+}
+
+func (s *signalService) Connect(string) error               { return nil }
+func (s *signalService) Handle(string, *markup.Lexer) error { return nil }
+func (s *signalService) Reload() error                      { s.reloads <- true; return nil }
+func (s *signalService) Start(c controller.Controller) error {
+	s.started <- c
+	select {}
+}
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestSignals(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	tr, server := transport.Pipe()
+	go io.Copy(io.Discard, bufio.NewReader(server))
+
+	var log syncBuffer
+	cb := &signalService{
+		started: make(chan controller.Controller),
+		reloads: make(chan bool, 1),
+	}
+	svc, err := Register(context.Background(), "zzyzx",
+		WithCallbacks(cb),
+		WithTransport(tr),
+		WithForeground(),
+		WithLogger(slog.New(slog.NewTextHandler(&log, nil))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error)
+	go func() { errs <- svc.Listen() }()
+	c := <-cb.started
+	c.CreateBuffer("#altid")
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+	select {
+	case <-cb.reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGHUP did not reload")
+	}
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	for i := 0; !strings.Contains(log.String(), "buffer=#altid"); i++ {
+		if i > 100 {
+			t.Fatalf("SIGUSR1 did not dump state, have %s", log.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	select {
+	case e := <-errs:
+		if e != ErrShutdown {
+			t.Errorf("expected ErrShutdown, have %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGTERM did not shut down")
+	}
+	if c.HasBuffer("#altid") {
+		t.Error("buffer not deleted on SIGTERM")
+	}
+}
+
+func TestWithSignals(t *testing.T) {
+	s := &Service{}
+	if e := WithSignals(map[os.Signal]string{syscall.SIGHUP: "explode"})(s); e == nil {
+		t.Error("expected an error for an unknown command")
+	}
+	if e := WithSignals(nil)(s); e != nil || len(s.signals) != 0 {
+		t.Errorf("unable to disable signals, have %v", s.signals)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package service
+
+import (
+	"os"
+	"syscall"
+)
+
+func defaultSignals() map[os.Signal]string {
+	return map[os.Signal]string{
+		syscall.SIGTERM: "shutdown",
+		syscall.SIGINT:  "shutdown",
+		syscall.SIGHUP:  "reload",
+		syscall.SIGUSR1: "dump",
+	}
+}
@@ -0,0 +1,9 @@
+package service
+
+import "os"
+
+func defaultSignals() map[os.Signal]string {
+	return map[os.Signal]string{
+		os.Interrupt: "shutdown",
+	}
+}
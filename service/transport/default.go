@@ -0,0 +1,27 @@
+//go:build !plan9
+// +build !plan9
+
+package transport
+
+import (
+	"os"
+)
+
+// Default returns the Transport used when a service does not supply one
+// If $ALTID_SOCKET is set, the server is dialed on that unix socket; if $ALTID_ADDR is set, on that tcp address
+// Otherwise the clone/ctl files are used from $ALTID_MOUNT, or /mnt/alt if unset
+func Default() Transport {
+	if sock := os.Getenv("ALTID_SOCKET"); sock != "" {
+		return Unix(sock)
+	}
+
+	if addr := os.Getenv("ALTID_ADDR"); addr != "" {
+		return TCP(addr)
+	}
+
+	if mnt := os.Getenv("ALTID_MOUNT"); mnt != "" {
+		return Mount(mnt)
+	}
+
+	return Mount("/mnt/alt")
+}
@@ -0,0 +1,6 @@
+package transport
+
+// Default returns the Transport used when a service does not supply one, the Altid server mounted on /mnt/alt
+func Default() Transport {
+	return Mount("/mnt/alt")
+}
@@ -0,0 +1,187 @@
+// Package transport connects Altid services to an Altid server
+//
+// A Transport dials the server and registers a service by name, returning a Conn that stands in for the service's ctl file.
+// Incoming commands are read from the Conn, and all output is written to it.
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Transport dials an Altid server on behalf of a service
+type Transport interface {
+	// Dial registers the named service with the server, returning a connection to its ctl file
+	Dial(ctx context.Context, name string) (Conn, error)
+}
+
+// Conn is a service's connection to its ctl file
+type Conn interface {
+	io.ReadWriteCloser
+	// Writer returns a handle used by a single output writer
+	// Transports which cannot reopen the ctl file return a handle sharing the Conn, whose Close leaves the Conn open
+	Writer() (io.WriteCloser, error)
+}
+
+// Mount returns a Transport using the clone/ctl files of an Altid server mounted at dir
+// Reading clone returns the id of a fresh ctl directory; writing our name to that ctl registers the service
+// Opening a file can't be interrupted, so Dial only checks its ctx between opens
+func Mount(dir string) Transport { return mount(dir) }
+
+// Unix returns a Transport which dials an Altid server listening on the unix socket at addr
+func Unix(addr string) Transport { return &socket{network: "unix", addr: addr} }
+
+// TCP returns a Transport which dials an Altid server listening on the tcp address addr
+func TCP(addr string) Transport { return &socket{network: "tcp", addr: addr} }
+
+// Pipe returns a Transport backed by an in-memory pipe, and the server end of that pipe
+// The first line read from the server end is the name of the registered service
+// Pipe is meant for test harnesses, and can only be dialed once
+func Pipe() (Transport, io.ReadWriteCloser) {
+	client, server := net.Pipe()
+	return &pipe{conn: client}, server
+}
+
+type mount string
+
+func (m mount) Dial(ctx context.Context, name string) (Conn, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+	b := make([]byte, 32)
+	cfd, err := os.Open(path.Join(string(m), "clone"))
+	if err != nil {
+		return nil, err
+	}
+	defer cfd.Close()
+
+	n, err := cfd.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+	cf := path.Join(string(m), strings.TrimSpace(string(b[:n])), "ctl")
+	wfd, err := os.OpenFile(cf, os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if e := ctx.Err(); e != nil {
+		wfd.Close()
+		return nil, e
+	}
+
+	rfd, err := os.Open(cf)
+	if err != nil {
+		wfd.Close()
+		return nil, err
+	}
+
+	fc := &fileConn{
+		path: cf,
+		rfd:  rfd,
+		wfd:  wfd,
+	}
+
+	// This creates /srv/$name, and returns our ctl file handle
+	if e := register(fc, name); e != nil {
+		return nil, e
+	}
+
+	return fc, nil
+}
+
+type fileConn struct {
+	path string
+	rfd  *os.File
+	wfd  *os.File
+}
+
+func (f *fileConn) Read(b []byte) (int, error)  { return f.rfd.Read(b) }
+func (f *fileConn) Write(b []byte) (int, error) { return f.wfd.Write(b) }
+
+// Each writer gets its own handle on the ctl file
+func (f *fileConn) Writer() (io.WriteCloser, error) {
+	return os.OpenFile(f.path, os.O_APPEND|os.O_WRONLY, 0644)
+}
+
+func (f *fileConn) Close() error {
+	rerr := f.rfd.Close()
+	if werr := f.wfd.Close(); werr != nil {
+		return werr
+	}
+	return rerr
+}
+
+type socket struct {
+	network string
+	addr    string
+}
+
+func (s *socket) Dial(ctx context.Context, name string) (Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &streamConn{conn}
+	if e := register(sc, name); e != nil {
+		return nil, e
+	}
+
+	return sc, nil
+}
+
+type pipe struct {
+	sync.Mutex
+	conn net.Conn
+}
+
+func (p *pipe) Dial(ctx context.Context, name string) (Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+	if p.conn == nil {
+		return nil, errors.New("pipe transport already dialed")
+	}
+
+	sc := &streamConn{p.conn}
+	p.conn = nil
+	if e := register(sc, name); e != nil {
+		return nil, e
+	}
+
+	return sc, nil
+}
+
+// streamConn wraps connections which cannot be reopened, so all writers share the one connection
+type streamConn struct {
+	net.Conn
+}
+
+func (s *streamConn) Writer() (io.WriteCloser, error) { return nopCloser{s.Conn}, nil }
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// The first line written to a ctl registers the service
+func register(c Conn, name string) error {
+	if _, err := fmt.Fprintf(c, "%s\n", name); err != nil {
+		c.Close()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	mnt := t.TempDir()
+	if e := os.WriteFile(path.Join(mnt, "clone"), []byte("1\n"), 0644); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.Mkdir(path.Join(mnt, "1"), 0755); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.WriteFile(path.Join(mnt, "1", "ctl"), nil, 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	conn, err := Mount(mnt).Dial(context.Background(), "zzyzx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w, err := conn.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "title zzyzx\n\tfoo")
+	w.Close()
+
+	b, err := os.ReadFile(path.Join(mnt, "1", "ctl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "zzyzx\ntitle zzyzx\n\tfoo" {
+		t.Errorf("unexpected ctl contents %q", b)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Mount(mnt).Dial(ctx, "zzyzx"); err != context.Canceled {
+		t.Errorf("expected context.Canceled, have %v", err)
+	}
+}
+
+func TestSockets(t *testing.T) {
+	unix := path.Join(t.TempDir(), "altid")
+	for network, addr := range map[string]string{"unix": unix, "tcp": "127.0.0.1:0"} {
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		name := make(chan string)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				close(name)
+				return
+			}
+			defer conn.Close()
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			name <- line
+		}()
+
+		tr := Unix(ln.Addr().String())
+		if network == "tcp" {
+			tr = TCP(ln.Addr().String())
+		}
+
+		conn, err := tr.Dial(context.Background(), "zzyzx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line := <-name; line != "zzyzx\n" {
+			t.Errorf("%s: service was not registered, found %q", network, line)
+		}
+		conn.Close()
+	}
+}
+
+func TestPipe(t *testing.T) {
+	tr, server := Pipe()
+	defer server.Close()
+
+	name := make(chan string)
+	go func() {
+		line, _ := bufio.NewReader(server).ReadString('\n')
+		name <- line
+	}()
+
+	conn, err := tr.Dial(context.Background(), "zzyzx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if line := <-name; line != "zzyzx\n" {
+		t.Errorf("service was not registered, found %q", line)
+	}
+
+	if _, err := tr.Dial(context.Background(), "zzyzx"); err == nil {
+		t.Error("pipe transport dialed twice")
+	}
+}
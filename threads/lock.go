@@ -0,0 +1,158 @@
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package threads
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lockEnv tells the detached copy of the service that it inherited the lock on lockFd
+const (
+	lockEnv = "ALTID_LOCKED"
+	lockFd  = 4
+)
+
+// How long a takeover waits for the old instance to exit
+const takeoverTimeout = 10 * time.Second
+
+// lock is the open pidfile while the lock is held
+var lock *os.File
+
+// Lock takes an advisory lock on PidFile, so only one instance of the service runs under its name, and writes our pid to it
+// If another instance holds the lock, a *LockedError naming it is returned
+// With takeover, the other instance is instead sent SIGTERM, and Lock waits for it to exit
+func Lock(takeover bool) error {
+	if lock != nil {
+		return &LockedError{Name: name, Pid: os.Getpid()}
+	}
+	// We were detached, and the lock came with us
+	if locked && daemon {
+		locked = false
+		if lock = adopt(); lock != nil {
+			return nil
+		}
+	}
+
+	file := PidFile()
+	if e := os.MkdirAll(path.Dir(file), 0700); e != nil {
+		return e
+	}
+	f, err := lockFile(file, takeover)
+	if err != nil {
+		return err
+	}
+
+	if e := f.Truncate(0); e != nil {
+		f.Close()
+		return e
+	}
+	if _, e := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); e != nil {
+		f.Close()
+		return e
+	}
+	lock = f
+	return nil
+}
+
+// adopt returns the lock passed on lockFd by detach, or nil if lockFd isn't a locked PidFile
+func adopt() *os.File {
+	var have syscall.Stat_t
+	if syscall.Fstat(lockFd, &have) != nil {
+		return nil
+	}
+	info, err := os.Stat(PidFile())
+	if err != nil {
+		return nil
+	}
+	// As os.SameFile, but without wrapping the fd in an os.File, which would close it when collected
+	want, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || want.Dev != have.Dev || want.Ino != have.Ino {
+		return nil
+	}
+	f := os.NewFile(lockFd, PidFile())
+	// We share the lock with our parent's copy, so this only fails if it was never ours
+	if syscall.Flock(lockFd, syscall.LOCK_EX|syscall.LOCK_NB) != nil {
+		f.Close()
+		return nil
+	}
+	return f
+}
+
+// Unlock removes PidFile and releases the lock taken by Lock
+func Unlock() error {
+	if lock == nil {
+		return nil
+	}
+	os.Remove(PidFile())
+	err := lock.Close()
+	lock = nil
+	return err
+}
+
+// lockFile opens and locks file
+// The old instance removes its pidfile on exit, so after a takeover we may hold a lock on a file which is no longer there, and must open it again
+func lockFile(file string, takeover bool) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if e := flock(f, takeover); e != nil {
+			f.Close()
+			return nil, e
+		}
+		have, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if want, err := os.Stat(file); err == nil && os.SameFile(have, want) {
+			return f, nil
+		}
+		f.Close()
+	}
+}
+
+func flock(f *os.File, takeover bool) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if !errors.Is(err, syscall.EWOULDBLOCK) {
+		return err
+	}
+
+	pid := holder(f)
+	if !takeover || pid == 0 || pid == os.Getpid() {
+		return &LockedError{Name: name, Pid: pid}
+	}
+	if e := syscall.Kill(pid, syscall.SIGTERM); e != nil && e != syscall.ESRCH {
+		return fmt.Errorf("takeover of pid %d: %w", pid, e)
+	}
+
+	deadline := time.Now().Add(takeoverTimeout)
+	for time.Now().Before(deadline) {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("takeover: timed out waiting for pid %d to exit", pid)
+}
+
+// holder returns the pid written to the pidfile by the instance holding the lock, or 0 if it can't be read
+func holder(f *os.File) int {
+	b := make([]byte, 32)
+	n, _ := f.ReadAt(b, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b[:n])))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,11 @@
+package threads
+
+// Lock is not yet supported on Plan 9, and always succeeds
+func Lock(takeover bool) error {
+	return nil
+}
+
+// Unlock releases the lock taken by Lock
+func Unlock() error {
+	return nil
+}
@@ -0,0 +1,98 @@
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package threads
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLock takes the lock from a copy of the test binary, which holds it until killed
+func TestLock(t *testing.T) {
+	if daemon {
+		t.Skip("running as a detached copy for TestStart")
+	}
+	SetName("zzyzx")
+	if os.Getenv("THREADS_TEST_HOLD") != "" {
+		if e := Lock(false); e != nil {
+			t.Fatal(e)
+		}
+		fmt.Println("locked")
+		time.Sleep(time.Minute)
+		return
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLock$")
+	cmd.Env = append(os.Environ(), "THREADS_TEST_HOLD=1")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := cmd.Start(); e != nil {
+		t.Fatal(e)
+	}
+	defer cmd.Process.Kill()
+	if line, _ := bufio.NewReader(out).ReadString('\n'); line != "locked\n" {
+		t.Fatalf("holder did not take the lock, have %q", line)
+	}
+
+	var le *LockedError
+	if e := Lock(false); !errors.As(e, &le) || le.Pid != cmd.Process.Pid {
+		t.Fatalf("expected a LockedError naming pid %d, have %v", cmd.Process.Pid, e)
+	}
+
+	if e := Lock(true); e != nil {
+		t.Fatal(e)
+	}
+	defer Unlock()
+	if e := cmd.Wait(); e == nil {
+		t.Error("holder was not signalled")
+	}
+	b, err := os.ReadFile(PidFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(b)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("pidfile not updated after takeover, have %q", b)
+	}
+
+	Unlock()
+	if _, e := os.Stat(PidFile()); !os.IsNotExist(e) {
+		t.Error("pidfile not removed by Unlock")
+	}
+}
+
+// A marker without the locked pidfile on lockFd must not be trusted
+func TestLockAdopt(t *testing.T) {
+	if daemon {
+		t.Skip("running as a detached copy for TestStart")
+	}
+	SetName("zzyzx")
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	daemon, locked = true, true
+	defer func() { daemon, locked = false, false }()
+
+	if e := Lock(false); e != nil {
+		t.Fatal(e)
+	}
+	defer Unlock()
+	have, _ := lock.Stat()
+	if want, err := os.Stat(PidFile()); err != nil || !os.SameFile(have, want) {
+		t.Error("lock is not held on our pidfile")
+	}
+	if locked {
+		t.Error("lock marker not cleared")
+	}
+	if os.Getenv(lockEnv) != "" || os.Getenv(daemonEnv) != "" {
+		t.Error("markers left in the environment")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package threads
+
+import (
+	"os"
+	"syscall"
+)
+
+// Restart replaces the running service with a fresh copy of its binary, run with the same arguments
+// It must only be called once Start has returned, so the lock and pidfile are released first
+// On success, it doesn't return
+func Restart() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
@@ -0,0 +1,10 @@
+package threads
+
+import (
+	"errors"
+)
+
+// Restart is not supported on Windows
+func Restart() error {
+	return errors.New("restart is not supported on windows")
+}
@@ -1,8 +1,153 @@
-//go:build !plan9
-// +build !plan9
+//go:build !plan9 && !windows
+// +build !plan9,!windows
 
 package threads
 
-func Start(func runmain(), fg bool){
-	return
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"syscall"
+
+	"github.com/altid/libs/config"
+	"github.com/altid/libs/internal/dirs"
+)
+
+// daemonEnv marks the detached copy of the service, so it runs fn instead of detaching again
+const daemonEnv = "ALTID_DAEMON"
+
+// readyFd is closed by the original process once the pidfile is written
+const readyFd = 3
+
+// The markers left by detach, read once and cleared so they aren't passed on to a restart or anything the service runs
+var daemon, locked = os.Getenv(daemonEnv) != "", os.Getenv(lockEnv) != ""
+
+func init() {
+	os.Unsetenv(daemonEnv)
+	os.Unsetenv(lockEnv)
+}
+
+// Start runs fn, returning any error encountered
+// Unless fg is set, the service re-executes itself in a new session with stdin on /dev/null and stdout and stderr on a log file
+// The original process returns nil once the detached copy has started, and its pid is written to PidFile
+// A lock taken with Lock is handed to the detached copy, and released when fn returns
+func Start(fn func() error, fg bool) error {
+	if fg {
+		defer Unlock()
+		return fn()
+	}
+	if !daemon {
+		return detach()
+	}
+	daemon = false
+	// Wait for our pidfile, so removing it on exit can't race the write
+	if ready := inherited(readyFd, "ready", syscall.S_IFIFO); ready != nil {
+		io.Copy(io.Discard, ready)
+		ready.Close()
+	}
+	defer func() {
+		// Unlock removes the pidfile along with the lock; once it's released the pidfile may belong to whoever took over
+		if lock == nil {
+			os.Remove(PidFile())
+		}
+		Unlock()
+	}()
+	return fn()
+}
+
+// PidFile returns the path of the pidfile for the service, $XDG_RUNTIME_DIR/altid/<name>.pid
+// If $XDG_RUNTIME_DIR is not set, the user share directory is used instead
+func PidFile() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir, _ = dirs.UserShareDir()
+	}
+	return path.Join(dir, "altid", name+".pid")
+}
+
+// LogFile returns the file stdout and stderr are redirected to once detached, service.log in the directory from config.GetLogDir
+// If no log directory is configured, output is discarded
+func LogFile() string {
+	dir := config.GetLogDir(name)
+	if dir == "none" {
+		return os.DevNull
+	}
+	return path.Join(dir, "service.log")
+}
+
+func detach() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		return err
+	}
+	defer null.Close()
+
+	logfile := LogFile()
+	if e := os.MkdirAll(path.Dir(logfile), 0755); e != nil {
+		return e
+	}
+	out, err := os.OpenFile(logfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	defer w.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.Stdin = null
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.ExtraFiles = []*os.File{r}
+	if lock != nil {
+		cmd.Env = append(cmd.Env, lockEnv+"=1")
+		cmd.ExtraFiles = append(cmd.ExtraFiles, lock)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if e := cmd.Start(); e != nil {
+		return fmt.Errorf("detach: %w", e)
+	}
+
+	// Write the pidfile before returning, so init scripts find it as soon as we exit
+	if e := writePid(PidFile(), cmd.Process.Pid); e != nil {
+		cmd.Process.Kill()
+		return e
+	}
+	// The lock now belongs to the detached copy
+	if lock != nil {
+		lock.Close()
+		lock = nil
+	}
+	return cmd.Process.Release()
+}
+
+// inherited returns fd as a file if it's open and of the given type, such as syscall.S_IFIFO
+// Otherwise fd isn't what our parent passed us, and may be in use by the runtime
+func inherited(fd int, name string, mode uint32) *os.File {
+	var st syscall.Stat_t
+	if syscall.Fstat(fd, &st) != nil || uint32(st.Mode)&syscall.S_IFMT != mode {
+		return nil
+	}
+	return os.NewFile(uintptr(fd), name)
+}
+
+func writePid(file string, pid int) error {
+	if e := os.MkdirAll(path.Dir(file), 0700); e != nil {
+		return e
+	}
+	return os.WriteFile(file, []byte(strconv.Itoa(pid)+"\n"), 0644)
 }
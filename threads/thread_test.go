@@ -0,0 +1,89 @@
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package threads
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestStart detaches a copy of the test binary, which runs this test again with daemonEnv set
+func TestStart(t *testing.T) {
+	fn := func() error {
+		return os.WriteFile(os.Getenv("THREADS_TEST_MARKER"), []byte(strconv.Itoa(os.Getpid())), 0644)
+	}
+	SetName("zzyzx")
+	if daemon {
+		if e := Start(fn, false); e != nil {
+			t.Fatal(e)
+		}
+		return
+	}
+
+	tmp := t.TempDir()
+	marker := path.Join(tmp, "marker")
+	t.Setenv("XDG_RUNTIME_DIR", tmp)
+	t.Setenv("XDG_CONFIG_HOME", tmp)
+	t.Setenv("THREADS_TEST_MARKER", marker)
+
+	// The detached copy runs with our arguments, so make sure it only runs this test
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestStart$"}
+	err := Start(fn, false)
+	os.Args = args
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(PidFile())
+	if err != nil {
+		t.Fatalf("no pidfile written: %v", err)
+	}
+	pid := strings.TrimSpace(string(b))
+	if pid == strconv.Itoa(os.Getpid()) {
+		t.Fatal("pidfile contains the parent pid")
+	}
+	// Start released the child, but we're still its parent, so reap it before our TempDir goes away
+	defer func() {
+		n, _ := strconv.Atoi(pid)
+		var ws syscall.WaitStatus
+		if _, e := syscall.Wait4(n, &ws, 0, nil); e != nil {
+			t.Errorf("wait for detached copy: %v", e)
+		} else if ws.ExitStatus() != 0 {
+			t.Errorf("detached copy exited with %d", ws.ExitStatus())
+		}
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		m, err := os.ReadFile(marker)
+		_, perr := os.Stat(PidFile())
+		if err == nil && os.IsNotExist(perr) {
+			if string(m) != pid {
+				t.Errorf("detached pid %s does not match pidfile %s", m, pid)
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("detached service did not run, or left its pidfile behind")
+}
+
+func TestStartForeground(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	ran := false
+	if e := Start(func() error { ran = true; return nil }, true); e != nil {
+		t.Fatal(e)
+	}
+	if !ran {
+		t.Error("fn not run in the foreground")
+	}
+	if _, e := os.Stat(PidFile()); !os.IsNotExist(e) {
+		t.Error("pidfile written in the foreground")
+	}
+}
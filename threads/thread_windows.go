@@ -0,0 +1,7 @@
+package threads
+
+// Start runs fn, returning any error encountered
+// Backgrounding is not supported on Windows, so fn is always run in the foreground
+func Start(fn func() error, fg bool) error {
+	return fn()
+}
@@ -0,0 +1,30 @@
+// Package threads runs a service in the foreground, or detached in the background
+package threads
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// name identifies the service in log and pid file paths, the executable name by default
+var name = filepath.Base(os.Args[0])
+
+// SetName sets the service name used to find the log directory and pidfile
+// It must be called before Start
+func SetName(n string) {
+	name = n
+}
+
+// LockedError is returned from Lock when another instance of the service holds the lock
+type LockedError struct {
+	Name string
+	Pid  int
+}
+
+func (e *LockedError) Error() string {
+	if e.Pid == 0 {
+		return fmt.Sprintf("%s is already running", e.Name)
+	}
+	return fmt.Sprintf("%s is already running as pid %d", e.Name, e.Pid)
+}